@@ -0,0 +1,43 @@
+package internal
+
+import (
+	"errors"
+	"syscall"
+
+	"github.com/fsnotify/fsnotify"
+)
+
+// WatchBackend delivers filesystem events for a set of directories. Like
+// inotify, watches are not recursive: each directory is added on its own.
+type WatchBackend interface {
+	Add(dir string) error
+	Remove(dir string) error
+	Events() <-chan fsnotify.Event
+	Errors() <-chan error
+	Close() error
+}
+
+// fsnotifyBackend is the default backend using native OS notifications
+type fsnotifyBackend struct {
+	watcher *fsnotify.Watcher
+}
+
+func newFsnotifyBackend() (*fsnotifyBackend, error) {
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return nil, err
+	}
+	return &fsnotifyBackend{watcher: watcher}, nil
+}
+
+func (b *fsnotifyBackend) Add(dir string) error          { return b.watcher.Add(dir) }
+func (b *fsnotifyBackend) Remove(dir string) error       { return b.watcher.Remove(dir) }
+func (b *fsnotifyBackend) Events() <-chan fsnotify.Event { return b.watcher.Events }
+func (b *fsnotifyBackend) Errors() <-chan error          { return b.watcher.Errors }
+func (b *fsnotifyBackend) Close() error                  { return b.watcher.Close() }
+
+// isWatchLimitErr reports whether err means the OS ran out of inotify
+// watches or instances, in which case polling is the only way forward.
+func isWatchLimitErr(err error) bool {
+	return errors.Is(err, syscall.ENOSPC) || errors.Is(err, syscall.EMFILE)
+}
@@ -0,0 +1,157 @@
+package internal
+
+import (
+	"context"
+	"fmt"
+	"os/exec"
+	"runtime"
+	"strings"
+)
+
+// Command is a --build or --run command line split into words
+type Command struct {
+	Env  []string // leading VAR=value assignments
+	Args []string
+}
+
+// ParseCommand splits a command line the way a POSIX shell would, honoring
+// single and double quotes and backslash escapes. Leading VAR=value words
+// become environment assignments. Shell operators such as && and |, and
+// $ expansions outside single quotes, are rejected; those need shell mode.
+func ParseCommand(line string) (Command, error) {
+	var (
+		cmd        Command
+		words      []string
+		assignable []bool
+		word       strings.Builder
+		inWord     bool
+		quoted     bool // a quote or escape was seen before the first '=' of the word
+		sawEquals  bool
+	)
+
+	flush := func() {
+		if inWord {
+			words = append(words, word.String())
+			assignable = append(assignable, sawEquals && !quoted)
+		}
+		word.Reset()
+		inWord, quoted, sawEquals = false, false, false
+	}
+
+	for i := 0; i < len(line); i++ {
+		c := line[i]
+		switch {
+		case c == ' ' || c == '\t' || c == '\n':
+			flush()
+		case c == '\\':
+			if i+1 >= len(line) {
+				return cmd, fmt.Errorf("trailing backslash in %q", line)
+			}
+			i++
+			if line[i] != '\n' {
+				word.WriteByte(line[i])
+				inWord = true
+				quoted = quoted || !sawEquals
+			}
+		case c == '\'':
+			end := strings.IndexByte(line[i+1:], '\'')
+			if end < 0 {
+				return cmd, fmt.Errorf("unterminated single quote in %q", line)
+			}
+			word.WriteString(line[i+1 : i+1+end])
+			inWord = true
+			quoted = quoted || !sawEquals
+			i += end + 1
+		case c == '"':
+			i++
+			for ; i < len(line) && line[i] != '"'; i++ {
+				if line[i] == '\\' && i+1 < len(line) && strings.IndexByte("$`\"\\\n", line[i+1]) >= 0 {
+					i++
+					if line[i] == '\n' {
+						continue
+					}
+				} else if line[i] == '$' || line[i] == '`' {
+					// Expansions work inside double quotes too
+					return cmd, shellSyntaxError(line, line[i])
+				}
+				word.WriteByte(line[i])
+			}
+			if i >= len(line) {
+				return cmd, fmt.Errorf("unterminated double quote in %q", line)
+			}
+			inWord = true
+			quoted = quoted || !sawEquals
+		case strings.IndexByte("|&;<>()`$", c) >= 0:
+			return cmd, shellSyntaxError(line, c)
+		default:
+			if c == '=' && !sawEquals {
+				sawEquals = true
+			}
+			word.WriteByte(c)
+			inWord = true
+		}
+	}
+	flush()
+
+	for i, w := range words {
+		if len(cmd.Args) == 0 && assignable[i] && isEnvName(w[:strings.IndexByte(w, '=')]) {
+			cmd.Env = append(cmd.Env, w)
+			continue
+		}
+		cmd.Args = append(cmd.Args, w)
+	}
+	if len(cmd.Args) == 0 {
+		return cmd, fmt.Errorf("empty command %q", line)
+	}
+	return cmd, nil
+}
+
+// shellSyntaxError reports shell syntax that only shell mode can run, such
+// as operators and $VAR expansions
+func shellSyntaxError(line string, c byte) error {
+	return fmt.Errorf("command %q uses shell syntax %q; enable shell mode to run it through sh", line, string(c))
+}
+
+// isEnvName reports whether name is a valid shell variable name
+func isEnvName(name string) bool {
+	if name == "" {
+		return false
+	}
+	for i, c := range name {
+		if c == '_' || (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z') || (i > 0 && c >= '0' && c <= '9') {
+			continue
+		}
+		return false
+	}
+	return true
+}
+
+// command prepares cmdStr for execution, either through the system shell
+// or by splitting it into words ourselves. env is added on top of the
+// configured environment.
+func (r *Runner) command(ctx context.Context, cmdStr string, env ...string) (*exec.Cmd, error) {
+	if r.shell {
+		if runtime.GOOS == "windows" {
+			return r.withEnv(exec.CommandContext(ctx, "cmd", "/C", cmdStr), env, nil), nil
+		}
+		return r.withEnv(exec.CommandContext(ctx, "sh", "-c", cmdStr), env, nil), nil
+	}
+
+	parsed, err := ParseCommand(cmdStr)
+	if err != nil {
+		return nil, err
+	}
+	return r.withEnv(exec.CommandContext(ctx, parsed.Args[0], parsed.Args[1:]...), env, parsed.Env), nil
+}
+
+// withEnv sets the command's environment to ours plus the configured, extra
+// and inline overrides. The command gets its own process group, and
+// cancelling its context kills the whole group.
+func (r *Runner) withEnv(cmd *exec.Cmd, env, inline []string) *exec.Cmd {
+	cmd.Env = append(append(r.environ(), env...), inline...)
+	setProcessGroup(cmd)
+	cmd.Cancel = func() error {
+		return killProcessGroup(cmd.Process)
+	}
+	return cmd
+}
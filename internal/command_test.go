@@ -0,0 +1,68 @@
+package internal
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseCommand(t *testing.T) {
+	tests := []struct {
+		line string
+		env  []string
+		args []string
+	}{
+		{"go build -o app .", nil, []string{"go", "build", "-o", "app", "."}},
+		{"  ./app \t --flag  ", nil, []string{"./app", "--flag"}},
+		{`./app "hello world" 'a b'`, nil, []string{"./app", "hello world", "a b"}},
+		{`echo a\ b`, nil, []string{"echo", "a b"}},
+		{`echo 'a\b' "a\b"`, nil, []string{"echo", `a\b`, `a\b`}},
+		{`echo "say \"hi\" \$HOME \\"`, nil, []string{"echo", `say "hi" $HOME \`}},
+		{`echo '$HOME' \$PORT costs\$5`, nil, []string{"echo", "$HOME", "$PORT", "costs$5"}},
+		{`echo "" ''`, nil, []string{"echo", "", ""}},
+		{`echo "a"'b'c`, nil, []string{"echo", "abc"}},
+		{"PORT=8080 ./server", []string{"PORT=8080"}, []string{"./server"}},
+		{"A=1 B_2= ./server", []string{"A=1", "B_2="}, []string{"./server"}},
+		{`FOO="a b" ./server`, []string{"FOO=a b"}, []string{"./server"}},
+		{`"FOO=1" ./server`, nil, []string{"FOO=1", "./server"}},
+		{`F\OO=1 ./server`, nil, []string{"FOO=1", "./server"}},
+		{`FOO=a\ b ./server`, []string{"FOO=a b"}, []string{"./server"}},
+		{"1FOO=x ./server", nil, []string{"1FOO=x", "./server"}},
+		{"=x ./server", nil, []string{"=x", "./server"}},
+		{"./server PORT=8080", nil, []string{"./server", "PORT=8080"}},
+	}
+	for _, tt := range tests {
+		cmd, err := ParseCommand(tt.line)
+		if err != nil {
+			t.Errorf("ParseCommand(%q) error: %v", tt.line, err)
+			continue
+		}
+		if !reflect.DeepEqual(cmd.Env, tt.env) || !reflect.DeepEqual(cmd.Args, tt.args) {
+			t.Errorf("ParseCommand(%q) = env %q args %q, want env %q args %q", tt.line, cmd.Env, cmd.Args, tt.env, tt.args)
+		}
+	}
+}
+
+func TestParseCommandErrors(t *testing.T) {
+	for _, line := range []string{
+		"",
+		"   ",
+		"PORT=8080",
+		"go build && ./app",
+		"./app | tee log",
+		"./app > log",
+		"./app; ./other",
+		"echo $(date)",
+		"echo `date`",
+		"./app --port $PORT",
+		`./app "$HOME"`,
+		"./app ${PORT}",
+		"./app \"`date`\"",
+		`echo "unterminated`,
+		`echo 'unterminated`,
+		`echo trailing\`,
+	} {
+		if cmd, err := ParseCommand(line); err == nil {
+			t.Errorf("ParseCommand(%q) = %+v, want error", line, cmd)
+		}
+	}
+}
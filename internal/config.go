@@ -0,0 +1,187 @@
+package internal
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/BurntSushi/toml"
+	"gopkg.in/yaml.v3"
+)
+
+// ConfigFileNames lists the project config files looked up in the watched
+// directory, in order of preference.
+var ConfigFileNames = []string{
+	"goober.toml",
+	".goober.toml",
+	"goober.yaml",
+	".goober.yaml",
+	".goober.yml",
+}
+
+// Config is the project configuration loaded from goober.toml or .goober.yaml
+type Config struct {
+	Build    string            `toml:"build" yaml:"build"`
+	Run      string            `toml:"run" yaml:"run"`
+	Shell    bool              `toml:"shell" yaml:"shell"`
+	Dir      string            `toml:"dir" yaml:"dir"`
+	Debounce time.Duration     `toml:"debounce" yaml:"debounce"`
+	Include  []string          `toml:"include" yaml:"include"`
+	Ignore   []string          `toml:"ignore" yaml:"ignore"`
+	Env      map[string]string `toml:"env" yaml:"env"`
+	Hooks    Hooks             `toml:"hooks" yaml:"hooks"`
+	Stages   []Stage           `toml:"stages" yaml:"stages"`
+	Triggers []Trigger         `toml:"triggers" yaml:"triggers"`
+
+	StopSignal  string        `toml:"stop_signal" yaml:"stop_signal"`
+	KillTimeout time.Duration `toml:"kill_timeout" yaml:"kill_timeout"`
+	Ports       []int         `toml:"ports" yaml:"ports"`
+	PortTimeout time.Duration `toml:"port_timeout" yaml:"port_timeout"`
+	Ready       ReadyCheck    `toml:"ready" yaml:"ready"`
+	Restart     RestartPolicy `toml:"restart" yaml:"restart"`
+	Proxy       ProxyConfig   `toml:"proxy" yaml:"proxy"`
+	Sockets     []string      `toml:"sockets" yaml:"sockets"`
+	Test        TestConfig    `toml:"test" yaml:"test"`
+
+	// Editor opens source locations from the TUI, e.g. "code --goto {file}:{line}:{col}".
+	// Empty means $VISUAL or $EDITOR.
+	Editor string `toml:"editor" yaml:"editor"`
+
+	Poll         bool          `toml:"poll" yaml:"poll"`
+	PollInterval time.Duration `toml:"poll_interval" yaml:"poll_interval"`
+	PollMode     string        `toml:"poll_mode" yaml:"poll_mode"`
+}
+
+// ProxyConfig enables the live-reload reverse proxy
+type ProxyConfig struct {
+	Listen string `toml:"listen" yaml:"listen"` // e.g. ":3000"
+	Target string `toml:"target" yaml:"target"` // e.g. ":8080"
+
+	// Requests arriving during a restart are held for up to HoldTimeout,
+	// with at most QueueSize of them waiting at once
+	HoldTimeout time.Duration `toml:"hold_timeout" yaml:"hold_timeout"`
+	QueueSize   int           `toml:"queue_size" yaml:"queue_size"`
+}
+
+// Hooks are extra commands run around the build and stop steps
+type Hooks struct {
+	BeforeBuild []string `toml:"before_build" yaml:"before_build"`
+	AfterBuild  []string `toml:"after_build" yaml:"after_build"`
+	AfterStop   []string `toml:"after_stop" yaml:"after_stop"`
+}
+
+// DefaultConfig returns the configuration used when no file or flags are given
+func DefaultConfig() Config {
+	return Config{
+		Build:    "go build -o app",
+		Run:      "./app",
+		Dir:      ".",
+		Debounce: 750 * time.Millisecond,
+
+		StopSignal:  "SIGINT",
+		KillTimeout: 5 * time.Second,
+		PortTimeout: 10 * time.Second,
+		Proxy: ProxyConfig{
+			HoldTimeout: 30 * time.Second,
+			QueueSize:   100,
+		},
+		Restart: RestartPolicy{
+			Policy:      RestartNever,
+			Backoff:     time.Second,
+			MaxBackoff:  30 * time.Second,
+			MaxAttempts: 5,
+		},
+	}
+}
+
+// FindConfig returns the first config file found in dir, or "" if none exists
+func FindConfig(dir string) string {
+	for _, name := range ConfigFileNames {
+		path := filepath.Join(dir, name)
+		if info, err := os.Stat(path); err == nil && !info.IsDir() {
+			return path
+		}
+	}
+	return ""
+}
+
+// LoadConfig reads path on top of cfg, leaving fields the file doesn't set
+// untouched. A relative dir in the file is resolved against the file's directory.
+func LoadConfig(path string, cfg *Config) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".toml":
+		err = toml.Unmarshal(data, cfg)
+	case ".yaml", ".yml":
+		err = yaml.Unmarshal(data, cfg)
+	default:
+		return fmt.Errorf("unsupported config format: %s", path)
+	}
+	if err != nil {
+		return fmt.Errorf("parsing %s: %w", path, err)
+	}
+
+	if cfg.Dir != "" && !filepath.IsAbs(cfg.Dir) {
+		cfg.Dir = filepath.Join(filepath.Dir(path), cfg.Dir)
+	}
+	return nil
+}
+
+// WatchConfig derives the watcher settings from the project configuration
+func (c Config) WatchConfig() WatchConfig {
+	watch := DefaultWatchConfig()
+	watch.Roots = []string{c.Dir}
+	if len(c.Include) > 0 {
+		watch.Include = c.Include
+	}
+	watch.Exclude = append(watch.Exclude, c.Ignore...)
+	watch.Triggers = c.Triggers
+	if c.Debounce > 0 {
+		watch.Debounce = c.Debounce
+	}
+	watch.Poll = c.Poll
+	if c.PollInterval > 0 {
+		watch.PollInterval = c.PollInterval
+	}
+	if c.PollMode != "" {
+		watch.PollMode = c.PollMode
+	}
+	return watch
+}
+
+// WatchConfig describes which files the watcher observes and how long it
+// waits after the last change before rebuilding. Include and Exclude hold
+// doublestar globs matched against paths relative to the watched root; an
+// Include entry starting with "!" is treated as an exclusion.
+type WatchConfig struct {
+	Roots    []string
+	Include  []string
+	Exclude  []string
+	Debounce time.Duration
+
+	// Triggers map file patterns to actions other than a rebuild
+	Triggers []Trigger
+
+	// Poll forces the stat-polling backend instead of fsnotify
+	Poll         bool
+	PollInterval time.Duration
+	PollMode     string
+}
+
+// DefaultWatchConfig returns the configuration used when no flags are given
+func DefaultWatchConfig() WatchConfig {
+	return WatchConfig{
+		Roots:        []string{"."},
+		Include:      []string{"**/*.go"},
+		Exclude:      []string{"**/.git/**", "**/vendor/**"},
+		Debounce:     750 * time.Millisecond,
+		PollInterval: 500 * time.Millisecond,
+		PollMode:     PollModeStat,
+	}
+}
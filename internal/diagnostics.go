@@ -0,0 +1,97 @@
+package internal
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// Diagnostic is a single compiler or vet error pointing at a source location
+type Diagnostic struct {
+	Package string // import path from the preceding "# pkg" header, if any
+	File    string
+	Line    int
+	Column  int
+	Message string
+}
+
+// Key identifies a diagnostic regardless of the build it came from
+func (d Diagnostic) Key() string {
+	return d.String()
+}
+
+func (d Diagnostic) String() string {
+	if d.Column > 0 {
+		return fmt.Sprintf("%s:%d:%d: %s", d.File, d.Line, d.Column, d.Message)
+	}
+	return fmt.Sprintf("%s:%d: %s", d.File, d.Line, d.Message)
+}
+
+// diagnosticLine matches "file.go:12:5: message" and "file.go:12: message"
+var diagnosticLine = regexp.MustCompile(`^(\S+\.\w+):(\d+)(?::(\d+))?: (.+)$`)
+
+// ParseDiagnostics extracts file:line:col diagnostics from go build and
+// go vet output. "# pkg" headers set the package of the diagnostics that
+// follow, indented lines right after a diagnostic are folded into its
+// message, and exact duplicates are dropped.
+func ParseDiagnostics(output string) []Diagnostic {
+	var (
+		diags []Diagnostic
+		pkg   string
+		seen  = map[string]bool{}
+		last  = -1
+	)
+	for _, line := range strings.Split(output, "\n") {
+		line = strings.TrimRight(line, "\r")
+		if name, ok := strings.CutPrefix(line, "# "); ok {
+			pkg = strings.TrimSpace(name)
+			last = -1
+			continue
+		}
+		if m := diagnosticLine.FindStringSubmatch(strings.TrimPrefix(line, "vet: ")); m != nil {
+			lineNo, _ := strconv.Atoi(m[2])
+			col, _ := strconv.Atoi(m[3])
+			diag := Diagnostic{
+				Package: pkg,
+				File:    m[1],
+				Line:    lineNo,
+				Column:  col,
+				Message: m[4],
+			}
+			if seen[diag.Key()] {
+				last = -1
+				continue
+			}
+			seen[diag.Key()] = true
+			diags = append(diags, diag)
+			last = len(diags) - 1
+			continue
+		}
+		if !strings.HasPrefix(line, "\t") {
+			// Anything else ends the message
+			last = -1
+		} else if last >= 0 {
+			diags[last].Message += "\n" + strings.TrimSpace(line)
+		}
+	}
+	return diags
+}
+
+// sourceLocation matches a Go file position anywhere in a line, such as
+// the frames of a panic trace
+var sourceLocation = regexp.MustCompile(`(?:^|[\s(])([^\s(]+\.go):(\d+)(?::(\d+))?`)
+
+// ParseLocation finds the first file.go:line[:col] position in text
+func ParseLocation(text string) (Diagnostic, bool) {
+	if diags := ParseDiagnostics(text); len(diags) > 0 {
+		return diags[0], true
+	}
+	m := sourceLocation.FindStringSubmatch(text)
+	if m == nil {
+		return Diagnostic{}, false
+	}
+	line, _ := strconv.Atoi(m[2])
+	col, _ := strconv.Atoi(m[3])
+	return Diagnostic{File: m[1], Line: line, Column: col}, true
+}
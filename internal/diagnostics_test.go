@@ -0,0 +1,88 @@
+package internal
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseDiagnostics(t *testing.T) {
+	tests := []struct {
+		name   string
+		output string
+		want   []Diagnostic
+	}{
+		{"empty", "", nil},
+		{"no diagnostics", "ok  \texample.com/a\t0.01s\nBuild failed\n", nil},
+		{
+			"file line and column",
+			"./main.go:12:5: undefined: foo\n",
+			[]Diagnostic{{File: "./main.go", Line: 12, Column: 5, Message: "undefined: foo"}},
+		},
+		{
+			"no column",
+			"main.go:7: missing return\n",
+			[]Diagnostic{{File: "main.go", Line: 7, Message: "missing return"}},
+		},
+		{
+			"package headers",
+			"# example.com/a\na/a.go:1:2: undefined: x\n# example.com/b\nb/b.go:3:4: undefined: y\n",
+			[]Diagnostic{
+				{Package: "example.com/a", File: "a/a.go", Line: 1, Column: 2, Message: "undefined: x"},
+				{Package: "example.com/b", File: "b/b.go", Line: 3, Column: 4, Message: "undefined: y"},
+			},
+		},
+		{
+			"vet prefix",
+			"# example.com/a\nvet: a/a.go:9:3: unreachable code\n",
+			[]Diagnostic{{Package: "example.com/a", File: "a/a.go", Line: 9, Column: 3, Message: "unreachable code"}},
+		},
+		{
+			"indented lines folded into the message",
+			"main.go:5:9: cannot use x (variable of type int) as string value in return statement\n\thave int\n\twant string\nexit status 1\n\tunrelated\n",
+			[]Diagnostic{{File: "main.go", Line: 5, Column: 9, Message: "cannot use x (variable of type int) as string value in return statement\nhave int\nwant string"}},
+		},
+		{
+			"indented lines after a header are dropped",
+			"# example.com/a\n\tnote: module requires go 1.24\na/a.go:1:1: expected 'package'\n",
+			[]Diagnostic{{Package: "example.com/a", File: "a/a.go", Line: 1, Column: 1, Message: "expected 'package'"}},
+		},
+		{
+			"duplicates removed",
+			"main.go:3:2: undefined: x\n\tfirst\nmain.go:3:2: undefined: x\n\tsecond\nmain.go:4:2: undefined: x\n\r\n",
+			[]Diagnostic{
+				{File: "main.go", Line: 3, Column: 2, Message: "undefined: x\nfirst"},
+				{File: "main.go", Line: 4, Column: 2, Message: "undefined: x"},
+			},
+		},
+		{
+			"windows line endings",
+			"main.go:3:2: undefined: x\r\n",
+			[]Diagnostic{{File: "main.go", Line: 3, Column: 2, Message: "undefined: x"}},
+		},
+	}
+	for _, tt := range tests {
+		if got := ParseDiagnostics(tt.output); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: ParseDiagnostics(%q) = %+v, want %+v", tt.name, tt.output, got, tt.want)
+		}
+	}
+}
+
+func TestParseLocation(t *testing.T) {
+	tests := []struct {
+		text string
+		want Diagnostic
+		ok   bool
+	}{
+		{"Building...", Diagnostic{}, false},
+		{"main.go:3:2: undefined: x", Diagnostic{File: "main.go", Line: 3, Column: 2, Message: "undefined: x"}, true},
+		{"\t/src/app/handler.go:42 +0x1d", Diagnostic{File: "/src/app/handler.go", Line: 42}, true},
+		{"panic at (server.go:10:7)", Diagnostic{File: "server.go", Line: 10, Column: 7}, true},
+		{"see notes.txt:3", Diagnostic{}, false},
+	}
+	for _, tt := range tests {
+		got, ok := ParseLocation(tt.text)
+		if ok != tt.ok || got != tt.want {
+			t.Errorf("ParseLocation(%q) = %#v, %v, want %#v, %v", tt.text, got, ok, tt.want, tt.ok)
+		}
+	}
+}
@@ -0,0 +1,92 @@
+package internal
+
+import (
+	"errors"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// guiEditors open a window of their own, so the TUI doesn't need to step
+// aside while they run
+var guiEditors = map[string]bool{
+	"code":          true,
+	"code-insiders": true,
+	"codium":        true,
+	"subl":          true,
+	"zed":           true,
+}
+
+// EditorCommand returns the command that opens file at line and col, and
+// whether the editor runs inside the terminal. template is a command line
+// with {file}, {line} and {col} placeholders; when it is empty $VISUAL or
+// $EDITOR is used with the line-jump syntax of the editors we know about.
+func EditorCommand(template, file string, line, col int) (*exec.Cmd, bool, error) {
+	if line < 1 {
+		line = 1
+	}
+	if col < 1 {
+		col = 1
+	}
+
+	var args []string
+	if template != "" {
+		parsed, err := ParseCommand(template)
+		if err != nil {
+			return nil, false, err
+		}
+		replacer := strings.NewReplacer(
+			"{file}", file,
+			"{line}", strconv.Itoa(line),
+			"{col}", strconv.Itoa(col),
+		)
+		for _, arg := range parsed.Args {
+			args = append(args, replacer.Replace(arg))
+		}
+	} else {
+		editor := os.Getenv("VISUAL")
+		if editor == "" {
+			editor = os.Getenv("EDITOR")
+		}
+		if editor == "" {
+			return nil, false, errors.New("no editor configured: set $EDITOR or editor in the config file")
+		}
+		parsed, err := ParseCommand(editor)
+		if err != nil {
+			return nil, false, err
+		}
+		args = append(parsed.Args, lineJumpArgs(parsed.Args[0], file, line, col)...)
+	}
+	if len(args) == 0 {
+		return nil, false, errors.New("empty editor command")
+	}
+
+	cmd := exec.Command(args[0], args[1:]...)
+	return cmd, !guiEditors[editorName(args[0])], nil
+}
+
+// lineJumpArgs returns the arguments that make editor open file at line:col
+func lineJumpArgs(editor, file string, line, col int) []string {
+	position := strconv.Itoa(line) + ":" + strconv.Itoa(col)
+	switch editorName(editor) {
+	case "vi", "vim", "nvim", "gvim", "mvim":
+		return []string{"+call cursor(" + strconv.Itoa(line) + "," + strconv.Itoa(col) + ")", file}
+	case "emacs", "emacsclient":
+		return []string{"+" + position, file}
+	case "nano":
+		return []string{"+" + strconv.Itoa(line) + "," + strconv.Itoa(col), file}
+	case "code", "code-insiders", "codium":
+		return []string{"--goto", file + ":" + position}
+	case "hx", "helix", "subl", "zed":
+		return []string{file + ":" + position}
+	default:
+		return []string{file}
+	}
+}
+
+// editorName is the bare program name of an editor command
+func editorName(program string) string {
+	return strings.TrimSuffix(filepath.Base(program), ".exe")
+}
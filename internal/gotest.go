@@ -0,0 +1,432 @@
+package internal
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// TestConfig enables a test stage that runs go test on the packages
+// affected by a change once the new build is running
+type TestConfig struct {
+	Enabled bool          `toml:"enabled" yaml:"enabled"`
+	Args    []string      `toml:"args" yaml:"args"` // extra go test flags, e.g. ["-race"]
+	Timeout time.Duration `toml:"timeout" yaml:"timeout"`
+}
+
+// TestEvent is one line of go test -json output
+type TestEvent struct {
+	Time        time.Time
+	Action      string
+	Package     string
+	ImportPath  string // set on build events instead of Package
+	Test        string
+	Elapsed     float64
+	Output      string
+	FailedBuild string // import path of the build that made the package fail
+}
+
+// TestFailure is a failed test, or a package that failed without a failing
+// test (a build error or a crash in TestMain), with the output it produced
+type TestFailure struct {
+	Package string
+	Test    string
+	Output  string
+}
+
+// TestReport summarizes a test run
+type TestReport struct {
+	Packages  []string
+	Run       string // -run pattern, if any
+	Running   bool
+	Cancelled bool
+	Passed    int
+	Failed    int
+	Skipped   int
+	Failures  []TestFailure
+	Elapsed   time.Duration
+
+	pending map[[2]string]*pendingFailure // output per package and test while running
+	builds  map[string]string             // compiler output per build import path
+}
+
+type TestCallback func(report TestReport)
+type TestEventCallback func(event TestEvent)
+
+// SetTests configures the test stage
+func (r *Runner) SetTests(tests TestConfig) {
+	r.tests = tests
+}
+
+// SetTestCallback sets the callback that receives test reports, once when a
+// run starts and again when it finishes
+func (r *Runner) SetTestCallback(callback TestCallback) {
+	r.testCallback = callback
+}
+
+// SetTestEventCallback sets the callback that receives every go test -json
+// event as it happens
+func (r *Runner) SetTestEventCallback(callback TestEventCallback) {
+	r.testEventCallback = callback
+}
+
+// SetTestFilter sets the -run pattern used by test-only mode
+func (r *Runner) SetTestFilter(pattern string) {
+	r.buildMu.Lock()
+	defer r.buildMu.Unlock()
+	r.testFilter = pattern
+}
+
+// TestFilter returns the -run pattern used by test-only mode
+func (r *Runner) TestFilter() string {
+	r.buildMu.Lock()
+	defer r.buildMu.Unlock()
+	return r.testFilter
+}
+
+// RunTests runs go test on pkgs, or on every package if pkgs is empty,
+// cancelling any test run still in progress. pattern is passed to -run.
+func (r *Runner) RunTests(pkgs []string, pattern string) {
+	if len(pkgs) == 0 {
+		pkgs = []string{"./..."}
+	}
+	r.testPackages(r.supersedeBuild(), pkgs, pattern)
+}
+
+// reportTests passes report to the test callback, if any
+func (r *Runner) reportTests(report TestReport) {
+	if r.testCallback != nil {
+		r.testCallback(report)
+	}
+}
+
+// runTests runs go test on the packages affected by files, or on every
+// package when files is empty. The run is killed if ctx is cancelled.
+func (r *Runner) runTests(ctx context.Context, files []string) {
+	pkgs, err := affectedPackages(ctx, r.environ(), files)
+	if ctx.Err() != nil {
+		return
+	}
+	if err != nil {
+		r.log(fmt.Sprintf("Tests failed to start: %v", err), true)
+		return
+	}
+	if len(pkgs) == 0 {
+		r.log("No packages affected, skipping tests", false)
+		return
+	}
+	r.testPackages(ctx, pkgs, r.TestFilter())
+}
+
+// testPackages runs go test -json on pkgs and reports the results
+func (r *Runner) testPackages(ctx context.Context, pkgs []string, pattern string) {
+	if len(pkgs) == 1 && pkgs[0] == "./..." {
+		r.log("Testing all packages...", false)
+	} else if len(pkgs) == 1 {
+		r.log(fmt.Sprintf("Testing %s...", pkgs[0]), false)
+	} else {
+		r.log(fmt.Sprintf("Testing %d packages...", len(pkgs)), false)
+	}
+	report := TestReport{Packages: pkgs, Run: pattern, Running: true}
+	r.reportTests(report)
+
+	if r.tests.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, r.tests.Timeout)
+		defer cancel()
+	}
+
+	args := append([]string{"test", "-json"}, r.tests.Args...)
+	if pattern != "" {
+		args = append(args, "-run", pattern)
+	}
+	start := time.Now()
+	err := r.goTest(ctx, append(args, pkgs...), func(event TestEvent) {
+		report.add(event)
+		if r.testEventCallback != nil {
+			r.testEventCallback(event)
+		}
+	})
+	report.Running = false
+	report.Elapsed = time.Since(start)
+	report.finish()
+
+	if errors.Is(ctx.Err(), context.Canceled) {
+		report.Cancelled = true
+		r.reportTests(report)
+		return
+	}
+	if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+		r.log(fmt.Sprintf("Tests timed out after %v", r.tests.Timeout), true)
+	} else if err != nil && report.Passed+report.Failed+report.Skipped == 0 && len(report.Failures) == 0 {
+		r.log(fmt.Sprintf("Tests failed to run: %v", err), true)
+	}
+
+	counts := []string{fmt.Sprintf("%d passed", report.Passed)}
+	if report.Failed > 0 {
+		counts = append(counts, fmt.Sprintf("%d failed", report.Failed))
+	}
+	if report.Skipped > 0 {
+		counts = append(counts, fmt.Sprintf("%d skipped", report.Skipped))
+	}
+	r.log(fmt.Sprintf("Tests: %s in %v", strings.Join(counts, ", "), report.Elapsed.Round(10*time.Millisecond)),
+		len(report.Failures) > 0)
+	for _, failure := range report.Failures {
+		r.log(fmt.Sprintf("FAIL %s", failure.Name()), true)
+		if r.testCallback == nil {
+			r.logLines("", failure.Output, true)
+		}
+	}
+	r.reportTests(report)
+}
+
+// goTest runs go with args, which must include -json, and passes each
+// event to onEvent as it arrives
+func (r *Runner) goTest(ctx context.Context, args []string, onEvent func(TestEvent)) error {
+	cmd := r.withEnv(exec.CommandContext(ctx, "go", args...), nil, nil)
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return err
+	}
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	if err := cmd.Start(); err != nil {
+		return err
+	}
+
+	scanner := bufio.NewScanner(stdout)
+	scanner.Buffer(make([]byte, 64*1024), 4*1024*1024)
+	for scanner.Scan() {
+		var event TestEvent
+		if json.Unmarshal(scanner.Bytes(), &event) == nil {
+			onEvent(event)
+		}
+	}
+	io.Copy(io.Discard, stdout)
+
+	if err := cmd.Wait(); err != nil {
+		return &CommandError{Output: strings.TrimSpace(stderr.String()), Err: err}
+	}
+	return nil
+}
+
+// Name is the failure as shown to the user: package.Test, or the package
+func (f TestFailure) Name() string {
+	if f.Test == "" {
+		return f.Package
+	}
+	return f.Package + "." + f.Test
+}
+
+// add counts a test event towards the report
+func (t *TestReport) add(event TestEvent) {
+	switch event.Action {
+	case "build-output":
+		if t.builds == nil {
+			t.builds = map[string]string{}
+		}
+		t.builds[event.ImportPath] += event.Output
+	case "output":
+		t.appendOutput(event.Package, event.Test, event.Output)
+	case "pass":
+		if event.Test != "" {
+			t.Passed++
+		}
+	case "skip":
+		if event.Test != "" {
+			t.Skipped++
+		}
+	case "fail":
+		if event.Test != "" {
+			t.Failed++
+		}
+		f := t.failure(event.Package, event.Test)
+		f.failed = true
+		if build := t.builds[event.FailedBuild]; build != "" {
+			// Compiler errors first, then go test's FAIL line
+			output := f.output.String()
+			f.output.Reset()
+			f.output.WriteString(build + output)
+		}
+	}
+}
+
+// appendOutput records output for a test so it can be shown if it fails
+func (t *TestReport) appendOutput(pkg, test, output string) {
+	f := t.failure(pkg, test)
+	f.output.WriteString(output)
+}
+
+// failure returns the pending result for pkg and test, creating it
+func (t *TestReport) failure(pkg, test string) *pendingFailure {
+	if t.pending == nil {
+		t.pending = map[[2]string]*pendingFailure{}
+	}
+	key := [2]string{pkg, test}
+	f, ok := t.pending[key]
+	if !ok {
+		f = &pendingFailure{order: len(t.pending)}
+		t.pending[key] = f
+	}
+	return f
+}
+
+// finish turns the failed pending results into the report's failures. A
+// package is only listed on its own if none of its tests failed.
+func (t *TestReport) finish() {
+	failedPkgs := map[string]bool{}
+	for key, f := range t.pending {
+		if f.failed && key[1] != "" {
+			failedPkgs[key[0]] = true
+		}
+	}
+
+	type ordered struct {
+		order   int
+		failure TestFailure
+	}
+	var failures []ordered
+	for key, f := range t.pending {
+		if !f.failed || (key[1] == "" && failedPkgs[key[0]]) {
+			continue
+		}
+		failures = append(failures, ordered{f.order, TestFailure{
+			Package: key[0],
+			Test:    key[1],
+			Output:  strings.TrimRight(f.output.String(), "\n"),
+		}})
+	}
+	sort.Slice(failures, func(i, j int) bool { return failures[i].order < failures[j].order })
+
+	t.Failures = t.Failures[:0]
+	for _, f := range failures {
+		t.Failures = append(t.Failures, f.failure)
+	}
+	t.pending = nil
+	t.builds = nil
+}
+
+// pendingFailure collects a test's output until it is known to have failed
+type pendingFailure struct {
+	order  int
+	output strings.Builder
+	failed bool
+}
+
+// listedPackage is the part of go list -json output used to find the
+// packages affected by a change
+type listedPackage struct {
+	ImportPath string
+	Name       string
+	Dir        string
+	ForTest    string
+	DepOnly    bool
+	Standard   bool
+	Deps       []string
+}
+
+// affectedPackages returns the packages of the current module that contain
+// one of files, import one of them (directly or not), or have tests that
+// do. With no files, every package is affected.
+func affectedPackages(ctx context.Context, env []string, files []string) ([]string, error) {
+	if len(files) == 0 {
+		return []string{"./..."}, nil
+	}
+
+	cmd := exec.CommandContext(ctx, "go", "list", "-deps", "-test", "-json", "./...")
+	cmd.Env = env
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	out, err := cmd.Output()
+	if err != nil {
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			return nil, fmt.Errorf("go list: %s", msg)
+		}
+		return nil, fmt.Errorf("go list: %w", err)
+	}
+
+	var listed []listedPackage
+	dec := json.NewDecoder(bytes.NewReader(out))
+	for {
+		var pkg listedPackage
+		if err := dec.Decode(&pkg); err == io.EOF {
+			break
+		} else if err != nil {
+			return nil, fmt.Errorf("go list: %w", err)
+		}
+		if !pkg.Standard {
+			listed = append(listed, pkg)
+		}
+	}
+
+	// The packages matched by ./... and the directories they live in
+	roots := map[string]bool{}
+	byDir := map[string]string{}
+	for _, pkg := range listed {
+		if pkg.DepOnly || pkg.ForTest != "" || strings.HasSuffix(pkg.ImportPath, ".test") {
+			continue
+		}
+		roots[pkg.ImportPath] = true
+		byDir[pkg.Dir] = pkg.ImportPath
+	}
+
+	changed := map[string]bool{}
+	for _, file := range files {
+		abs, err := filepath.Abs(file)
+		if err != nil {
+			continue
+		}
+		dir := abs
+		if info, err := os.Stat(abs); err != nil || !info.IsDir() {
+			dir = filepath.Dir(abs)
+		}
+		if pkg, ok := byDir[dir]; ok {
+			changed[pkg] = true
+		}
+	}
+	if len(changed) == 0 {
+		return nil, nil
+	}
+
+	affected := map[string]bool{}
+	for _, pkg := range listed {
+		// Test variants and test mains count towards the package under test
+		owner := pkg.ImportPath
+		if pkg.ForTest != "" {
+			owner = pkg.ForTest
+		} else if pkg.Name == "main" && strings.HasSuffix(owner, ".test") {
+			owner = strings.TrimSuffix(owner, ".test")
+		}
+		if !roots[owner] || affected[owner] {
+			continue
+		}
+		if changed[owner] {
+			affected[owner] = true
+			continue
+		}
+		for _, dep := range pkg.Deps {
+			dep, _, _ = strings.Cut(dep, " ")
+			if changed[dep] {
+				affected[owner] = true
+				break
+			}
+		}
+	}
+
+	pkgs := make([]string, 0, len(affected))
+	for pkg := range affected {
+		pkgs = append(pkgs, pkg)
+	}
+	sort.Strings(pkgs)
+	return pkgs, nil
+}
@@ -0,0 +1,63 @@
+package internal
+
+import (
+	"crypto/sha256"
+	"sync"
+)
+
+// contentHashes remembers the contents of every watched file so that saves
+// which don't change anything (touch, no-op formatter runs, checking out
+// identical content) don't trigger a rebuild.
+type contentHashes struct {
+	mu   sync.Mutex
+	sums map[string][sha256.Size]byte
+}
+
+func newContentHashes() *contentHashes {
+	return &contentHashes{sums: map[string][sha256.Size]byte{}}
+}
+
+// changed reports whether path differs from the version recorded by the
+// last commit. New and deleted files always count as changed.
+func (h *contentHashes) changed(path string) bool {
+	sum, err := hashFile(path)
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	old, known := h.sums[path]
+	if err != nil {
+		return known
+	}
+	return !known || sum != old
+}
+
+// commit records the current contents of path as the known version and
+// reports whether they changed. Editors often truncate before writing, so
+// this is checked again once the debounce period is over.
+func (h *contentHashes) commit(path string) bool {
+	sum, err := hashFile(path)
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	old, known := h.sums[path]
+	if err != nil {
+		delete(h.sums, path)
+		return known
+	}
+	h.sums[path] = sum
+	return !known || sum != old
+}
+
+// changeBurst counts the files touched between two rebuilds, along with
+// the trigger each of them matched
+type changeBurst struct {
+	saved    map[string]bool
+	changed  map[string]bool
+	triggers map[string]int
+}
+
+func newChangeBurst() changeBurst {
+	return changeBurst{saved: map[string]bool{}, changed: map[string]bool{}, triggers: map[string]int{}}
+}
@@ -0,0 +1,159 @@
+package internal
+
+import (
+	"bufio"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"github.com/bmatcuk/doublestar/v4"
+)
+
+// IgnoreFileNames are the per-directory ignore files goober honors. Both use
+// gitignore syntax; rules in deeper directories override their parents.
+var IgnoreFileNames = []string{".gitignore", ".gooberignore"}
+
+// ignoreRule is a single parsed gitignore line
+type ignoreRule struct {
+	pattern string
+	negate  bool
+	dirOnly bool
+}
+
+// match reports whether rel, relative to the ignore file's directory, matches
+func (r ignoreRule) match(rel string, isDir bool) bool {
+	if r.dirOnly && !isDir {
+		return false
+	}
+	ok, _ := doublestar.Match(r.pattern, rel)
+	return ok
+}
+
+// ignoreSet holds the ignore rules loaded so far, keyed by directory
+type ignoreSet struct {
+	mu    sync.RWMutex
+	rules map[string][]ignoreRule
+}
+
+func newIgnoreSet() *ignoreSet {
+	return &ignoreSet{rules: map[string][]ignoreRule{}}
+}
+
+// load reads the ignore files in dir, replacing any rules loaded earlier.
+// For a watched root, .git/info/exclude is read as well.
+func (s *ignoreSet) load(dir string, isRoot bool) {
+	var rules []ignoreRule
+	if isRoot {
+		rules = append(rules, parseIgnoreFile(filepath.Join(dir, ".git", "info", "exclude"))...)
+	}
+	for _, name := range IgnoreFileNames {
+		rules = append(rules, parseIgnoreFile(filepath.Join(dir, name))...)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if len(rules) == 0 {
+		delete(s.rules, dir)
+	} else {
+		s.rules[dir] = rules
+	}
+}
+
+// forget drops the rules of dir and every directory below it
+func (s *ignoreSet) forget(dir string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	prefix := dir + string(filepath.Separator)
+	for d := range s.rules {
+		if d == dir || strings.HasPrefix(d, prefix) {
+			delete(s.rules, d)
+		}
+	}
+}
+
+// ignored reports whether rel (slash-separated, relative to root) is ignored.
+// As in git, a path inside an ignored directory can't be re-included.
+func (s *ignoreSet) ignored(root, rel string, isDir bool) bool {
+	if rel == "." || rel == "" {
+		return false
+	}
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	parts := strings.Split(rel, "/")
+	for i := range parts {
+		last := i == len(parts)-1
+		if s.matches(root, parts[:i+1], isDir || !last) {
+			return true
+		}
+	}
+	return false
+}
+
+// matches applies the rules of every directory from root down to the parent
+// of parts; the last matching rule wins.
+func (s *ignoreSet) matches(root string, parts []string, isDir bool) bool {
+	ignored := false
+	dir := root
+	for i := range parts {
+		sub := strings.Join(parts[i:], "/")
+		for _, rule := range s.rules[dir] {
+			if rule.match(sub, isDir) {
+				ignored = !rule.negate
+			}
+		}
+		dir = filepath.Join(dir, parts[i])
+	}
+	return ignored
+}
+
+// parseIgnoreFile reads gitignore rules from path; a missing file has none
+func parseIgnoreFile(path string) []ignoreRule {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil
+	}
+	defer file.Close()
+
+	var rules []ignoreRule
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		if rule, ok := parseIgnoreLine(scanner.Text()); ok {
+			rules = append(rules, rule)
+		}
+	}
+	return rules
+}
+
+// parseIgnoreLine turns one gitignore line into a rule
+func parseIgnoreLine(line string) (ignoreRule, bool) {
+	line = strings.TrimRight(line, " \t\r")
+	if line == "" || strings.HasPrefix(line, "#") {
+		return ignoreRule{}, false
+	}
+
+	var rule ignoreRule
+	if strings.HasPrefix(line, "!") {
+		rule.negate = true
+		line = line[1:]
+	} else if strings.HasPrefix(line, `\!`) || strings.HasPrefix(line, `\#`) {
+		line = line[1:]
+	}
+	if strings.HasSuffix(line, "/") {
+		rule.dirOnly = true
+		line = strings.TrimRight(line, "/")
+	}
+	if line == "" {
+		return ignoreRule{}, false
+	}
+
+	// A slash anywhere but the end anchors the pattern to the file's directory
+	if strings.Contains(line, "/") {
+		line = strings.TrimPrefix(line, "/")
+	} else {
+		line = "**/" + line
+	}
+	rule.pattern = line
+	return rule, true
+}
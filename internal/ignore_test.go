@@ -0,0 +1,95 @@
+package internal
+
+import (
+	"path/filepath"
+	"testing"
+)
+
+func TestParseIgnoreLine(t *testing.T) {
+	tests := []struct {
+		line string
+		rule ignoreRule
+		ok   bool
+	}{
+		{"", ignoreRule{}, false},
+		{"   ", ignoreRule{}, false},
+		{"# comment", ignoreRule{}, false},
+		{"/", ignoreRule{}, false},
+		{"!", ignoreRule{}, false},
+		{"*.log", ignoreRule{pattern: "**/*.log"}, true},
+		{"*.log  \r", ignoreRule{pattern: "**/*.log"}, true},
+		{"/bin", ignoreRule{pattern: "bin"}, true},
+		{"tmp/", ignoreRule{pattern: "**/tmp", dirOnly: true}, true},
+		{"/build/", ignoreRule{pattern: "build", dirOnly: true}, true},
+		{"docs/*.md", ignoreRule{pattern: "docs/*.md"}, true},
+		{"**/gen/*.go", ignoreRule{pattern: "**/gen/*.go"}, true},
+		{"!keep.log", ignoreRule{pattern: "**/keep.log", negate: true}, true},
+		{"!/out/", ignoreRule{pattern: "out", negate: true, dirOnly: true}, true},
+		{`\!important`, ignoreRule{pattern: "**/!important"}, true},
+		{`\#hash`, ignoreRule{pattern: "**/#hash"}, true},
+	}
+	for _, tt := range tests {
+		rule, ok := parseIgnoreLine(tt.line)
+		if ok != tt.ok || rule != tt.rule {
+			t.Errorf("parseIgnoreLine(%q) = %+v, %v, want %+v, %v", tt.line, rule, ok, tt.rule, tt.ok)
+		}
+	}
+}
+
+func TestIgnoreSetIgnored(t *testing.T) {
+	root := filepath.FromSlash("/project")
+	s := newIgnoreSet()
+	for dir, lines := range map[string][]string{
+		root:                       {"*.log", "!keep.log", "/bin", "tmp/", "build/", "docs/*.md"},
+		filepath.Join(root, "sub"): {"*.txt", "!debug.log"},
+	} {
+		for _, line := range lines {
+			if rule, ok := parseIgnoreLine(line); ok {
+				s.rules[dir] = append(s.rules[dir], rule)
+			}
+		}
+	}
+
+	tests := []struct {
+		rel   string
+		isDir bool
+		want  bool
+	}{
+		{".", true, false},
+		{"main.go", false, false},
+
+		// Unanchored patterns match at any depth, and later rules win
+		{"app.log", false, true},
+		{"a/b/app.log", false, true},
+		{"keep.log", false, false},
+		{"a/keep.log", false, false},
+
+		// Anchored patterns only match at the root
+		{"bin", true, true},
+		{"bin/app", false, true},
+		{"cmd/bin", true, false},
+		{"cmd/bin/main.go", false, false},
+		{"docs/readme.md", false, true},
+		{"a/docs/readme.md", false, false},
+
+		// Directory-only patterns
+		{"tmp", false, false},
+		{"tmp", true, true},
+		{"a/tmp/x.go", false, true},
+
+		// A file in an ignored directory can't be re-included
+		{"build/keep.log", false, true},
+
+		// Nested ignore files apply below their directory and override
+		// their parents
+		{"sub/notes.txt", false, true},
+		{"notes.txt", false, false},
+		{"sub/debug.log", false, false},
+		{"sub/other.log", false, true},
+	}
+	for _, tt := range tests {
+		if got := s.ignored(root, tt.rel, tt.isDir); got != tt.want {
+			t.Errorf("ignored(%q, dir=%v) = %v, want %v", tt.rel, tt.isDir, got, tt.want)
+		}
+	}
+}
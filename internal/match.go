@@ -0,0 +1,152 @@
+package internal
+
+import (
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/bmatcuk/doublestar/v4"
+)
+
+// pathMatcher decides which paths under the watched roots are relevant
+type pathMatcher struct {
+	ignores  *ignoreSet
+	roots    []string
+	include  []string
+	exclude  []string
+	triggers []triggerPatterns
+}
+
+func newPathMatcher(cfg WatchConfig) *pathMatcher {
+	m := &pathMatcher{ignores: newIgnoreSet(), triggers: compileTriggers(cfg.Triggers)}
+	for _, root := range cfg.Roots {
+		if abs, err := filepath.Abs(root); err == nil {
+			root = abs
+		}
+		m.roots = append(m.roots, root)
+	}
+	for _, pattern := range cfg.Include {
+		if negated, ok := strings.CutPrefix(pattern, "!"); ok {
+			m.exclude = append(m.exclude, normalizePattern(negated))
+		} else {
+			m.include = append(m.include, normalizePattern(pattern))
+		}
+	}
+	for _, pattern := range cfg.Exclude {
+		m.exclude = append(m.exclude, normalizePattern(strings.TrimPrefix(pattern, "!")))
+	}
+	return m
+}
+
+// normalizePattern makes a pattern without a slash match at any depth,
+// so "tmp" and "*.log" behave like they do in .gitignore.
+func normalizePattern(pattern string) string {
+	pattern = filepath.ToSlash(strings.TrimSpace(pattern))
+	pattern = strings.TrimSuffix(pattern, "/")
+	if trimmed, ok := strings.CutPrefix(pattern, "/"); ok {
+		return trimmed
+	}
+	if !strings.Contains(pattern, "/") {
+		return "**/" + pattern
+	}
+	return pattern
+}
+
+// rel returns path relative to the root containing it, in slash form,
+// along with that root
+func (m *pathMatcher) rel(p string) (string, string, bool) {
+	if abs, err := filepath.Abs(p); err == nil {
+		p = abs
+	}
+	for _, root := range m.roots {
+		rel, err := filepath.Rel(root, p)
+		if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+			continue
+		}
+		return root, filepath.ToSlash(rel), true
+	}
+	return "", "", false
+}
+
+// loadIgnores reads the ignore files found in dir
+func (m *pathMatcher) loadIgnores(dir string) {
+	root, rel, ok := m.rel(dir)
+	if !ok {
+		return
+	}
+	m.ignores.load(filepath.Join(root, filepath.FromSlash(rel)), rel == ".")
+}
+
+// forgetIgnores drops the ignore rules of dir and its subdirectories
+func (m *pathMatcher) forgetIgnores(dir string) {
+	root, rel, ok := m.rel(dir)
+	if !ok {
+		return
+	}
+	m.ignores.forget(filepath.Join(root, filepath.FromSlash(rel)))
+}
+
+// isIgnoreFile reports whether path is a .gitignore or .gooberignore file
+func isIgnoreFile(p string) bool {
+	base := filepath.Base(p)
+	for _, name := range IgnoreFileNames {
+		if base == name {
+			return true
+		}
+	}
+	return false
+}
+
+// excluded reports whether rel or any of its parent directories is excluded
+func (m *pathMatcher) excluded(rel string) bool {
+	for p := rel; p != "." && p != "/" && p != ""; p = path.Dir(p) {
+		if matchAny(m.exclude, p) {
+			return true
+		}
+	}
+	return false
+}
+
+// skipsDir reports whether a directory should be left out of the watch set
+func (m *pathMatcher) skipsDir(dir string) bool {
+	root, rel, ok := m.rel(dir)
+	if !ok || rel == "." {
+		return false
+	}
+	return m.excluded(rel) || m.ignores.ignored(root, rel, true)
+}
+
+// matchesFile reports whether a change to file should be acted on
+func (m *pathMatcher) matchesFile(file string) bool {
+	_, matched := m.classify(file)
+	return matched
+}
+
+// classify reports whether a change to file should be acted on, and the
+// index of the first trigger matching it, or -1 if none does. Triggers
+// take precedence over include, but excluded and ignored
+// files stay out of reach.
+func (m *pathMatcher) classify(file string) (int, bool) {
+	root, rel, ok := m.rel(file)
+	if !ok || m.excluded(rel) || m.ignores.ignored(root, rel, false) {
+		return -1, false
+	}
+	for i, t := range m.triggers {
+		if matchAny(t.patterns, rel) {
+			return i, t.action != TriggerIgnore
+		}
+	}
+	if len(m.include) == 0 || matchAny(m.include, rel) {
+		return -1, true
+	}
+	return -1, false
+}
+
+func matchAny(patterns []string, name string) bool {
+	for _, pattern := range patterns {
+		if ok, _ := doublestar.Match(pattern, name); ok {
+			return true
+		}
+	}
+	return false
+}
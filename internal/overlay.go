@@ -0,0 +1,90 @@
+package internal
+
+import (
+	"bufio"
+	"html/template"
+	"net/http"
+	"os"
+)
+
+// sourceContextLines is how many lines are shown around each error
+const sourceContextLines = 3
+
+// sourceLine is one line of code shown around a diagnostic
+type sourceLine struct {
+	Number int
+	Text   string
+	Error  bool
+}
+
+// overlayDiagnostic is a diagnostic together with the code it points at
+type overlayDiagnostic struct {
+	Diagnostic
+	Source []sourceLine
+}
+
+var overlayTemplate = template.Must(template.New("overlay").Parse(`<!DOCTYPE html>
+<html><head><meta charset="utf-8"><title>Build failed</title>
+<style>
+body{font-family:ui-monospace,monospace;background:#1a1b26;color:#c0caf5;margin:0;padding:2em}
+h1{color:#f7768e;margin-top:0}
+.diag{background:#1f2335;border-left:3px solid #f7768e;margin:1.5em 0;padding:1em}
+.loc{color:#7aa2f7}
+.msg{color:#f7768e;white-space:pre-wrap;margin:.5em 0}
+.src{color:#a9b1d6;margin:0}
+.src .n{color:#565f89;display:inline-block;width:4em;text-align:right;padding-right:1em}
+.src .err{background:#2d202a;color:#c0caf5}
+.hint{color:#565f89}
+</style></head>
+<body>
+<h1>Build failed</h1>
+{{if .Diagnostics}}{{range .Diagnostics}}<div class="diag">
+<div class="loc">{{.File}}:{{.Line}}{{if .Column}}:{{.Column}}{{end}}</div>
+<div class="msg">{{.Message}}</div>
+{{if .Source}}<pre class="src">{{range .Source}}<span{{if .Error}} class="err"{{end}}><span class="n">{{.Number}}</span>{{.Text}}</span>
+{{end}}</pre>{{end}}
+</div>{{end}}{{else}}<pre class="msg">{{.Output}}</pre>{{end}}
+<p class="hint">This page reloads once the build is fixed.</p>
+{{.Script}}
+</body></html>`))
+
+// serveBuildError answers with a 502 page listing the compiler errors with
+// the code around them. The page reloads itself once the build is fixed.
+func serveBuildError(w http.ResponseWriter, output string, diags []Diagnostic) {
+	data := struct {
+		Output      string
+		Diagnostics []overlayDiagnostic
+		Script      template.HTML
+	}{Output: output, Script: template.HTML(reloadScript)}
+
+	for _, diag := range diags {
+		data.Diagnostics = append(data.Diagnostics, overlayDiagnostic{
+			Diagnostic: diag,
+			Source:     readSourceContext(diag.File, diag.Line),
+		})
+	}
+
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(http.StatusBadGateway)
+	overlayTemplate.Execute(w, data)
+}
+
+// readSourceContext returns the lines around line in file, or nil if the
+// file can't be read
+func readSourceContext(file string, line int) []sourceLine {
+	f, err := os.Open(file)
+	if err != nil {
+		return nil
+	}
+	defer f.Close()
+
+	var lines []sourceLine
+	scanner := bufio.NewScanner(f)
+	for n := 1; scanner.Scan() && n <= line+sourceContextLines; n++ {
+		if n >= line-sourceContextLines {
+			lines = append(lines, sourceLine{Number: n, Text: scanner.Text(), Error: n == line})
+		}
+	}
+	return lines
+}
@@ -0,0 +1,204 @@
+package internal
+
+import (
+	"crypto/sha256"
+	"io"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+)
+
+// Poll modes for the polling backend
+const (
+	PollModeStat = "stat" // compare modification time and size
+	PollModeHash = "hash" // compare file contents
+)
+
+// fileState is what the poller remembers about a directory entry
+type fileState struct {
+	modTime time.Time
+	size    int64
+	isDir   bool
+	sum     [sha256.Size]byte
+}
+
+// pollBackend detects changes by periodically scanning the watched
+// directories. It works where inotify doesn't: Docker bind mounts, NFS and
+// VM shared folders.
+type pollBackend struct {
+	interval time.Duration
+	hash     bool
+
+	mu   sync.Mutex
+	dirs map[string]map[string]fileState
+
+	events chan fsnotify.Event
+	errors chan error
+	done   chan struct{}
+	once   sync.Once
+}
+
+func newPollBackend(interval time.Duration, mode string) *pollBackend {
+	if interval <= 0 {
+		interval = 500 * time.Millisecond
+	}
+	b := &pollBackend{
+		interval: interval,
+		hash:     mode == PollModeHash,
+		dirs:     map[string]map[string]fileState{},
+		events:   make(chan fsnotify.Event, 100),
+		errors:   make(chan error, 10),
+		done:     make(chan struct{}),
+	}
+	go b.loop()
+	return b
+}
+
+func (b *pollBackend) Add(dir string) error {
+	snapshot, err := b.scan(dir)
+	if err != nil {
+		return err
+	}
+	b.mu.Lock()
+	b.dirs[dir] = snapshot
+	b.mu.Unlock()
+	return nil
+}
+
+func (b *pollBackend) Remove(dir string) error {
+	b.mu.Lock()
+	delete(b.dirs, dir)
+	b.mu.Unlock()
+	return nil
+}
+
+func (b *pollBackend) Events() <-chan fsnotify.Event { return b.events }
+func (b *pollBackend) Errors() <-chan error          { return b.errors }
+
+func (b *pollBackend) Close() error {
+	b.once.Do(func() { close(b.done) })
+	return nil
+}
+
+// loop rescans every watched directory once per interval
+func (b *pollBackend) loop() {
+	ticker := time.NewTicker(b.interval)
+	defer ticker.Stop()
+	defer close(b.events)
+	defer close(b.errors)
+
+	for {
+		select {
+		case <-b.done:
+			return
+		case <-ticker.C:
+			for _, event := range b.poll() {
+				select {
+				case b.events <- event:
+				case <-b.done:
+					return
+				}
+			}
+		}
+	}
+}
+
+// poll compares a fresh scan of each directory with the previous one
+func (b *pollBackend) poll() []fsnotify.Event {
+	b.mu.Lock()
+	dirs := make([]string, 0, len(b.dirs))
+	for dir := range b.dirs {
+		dirs = append(dirs, dir)
+	}
+	b.mu.Unlock()
+
+	var events []fsnotify.Event
+	for _, dir := range dirs {
+		current, err := b.scan(dir)
+		if err != nil {
+			if os.IsNotExist(err) {
+				b.Remove(dir)
+				events = append(events, fsnotify.Event{Name: dir, Op: fsnotify.Remove})
+			}
+			continue
+		}
+
+		b.mu.Lock()
+		previous, ok := b.dirs[dir]
+		if ok {
+			b.dirs[dir] = current
+		}
+		b.mu.Unlock()
+		if !ok {
+			// Removed while we were scanning
+			continue
+		}
+
+		for name, state := range current {
+			path := filepath.Join(dir, name)
+			old, existed := previous[name]
+			switch {
+			case !existed:
+				events = append(events, fsnotify.Event{Name: path, Op: fsnotify.Create})
+			case !state.isDir && state.changedFrom(old, b.hash):
+				events = append(events, fsnotify.Event{Name: path, Op: fsnotify.Write})
+			}
+		}
+		for name := range previous {
+			if _, exists := current[name]; !exists {
+				events = append(events, fsnotify.Event{Name: filepath.Join(dir, name), Op: fsnotify.Remove})
+			}
+		}
+	}
+	return events
+}
+
+// scan records the state of every entry directly inside dir
+func (b *pollBackend) scan(dir string) (map[string]fileState, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	snapshot := make(map[string]fileState, len(entries))
+	for _, entry := range entries {
+		info, err := entry.Info()
+		if err != nil {
+			continue
+		}
+		state := fileState{modTime: info.ModTime(), size: info.Size(), isDir: info.IsDir()}
+		if b.hash && info.Mode().IsRegular() {
+			state.sum, _ = hashFile(filepath.Join(dir, entry.Name()))
+		}
+		snapshot[entry.Name()] = state
+	}
+	return snapshot, nil
+}
+
+// changedFrom reports whether a file differs from its previous state
+func (s fileState) changedFrom(old fileState, byHash bool) bool {
+	if byHash {
+		return s.sum != old.sum
+	}
+	return !s.modTime.Equal(old.modTime) || s.size != old.size
+}
+
+// hashFile returns the SHA-256 of a file's contents
+func hashFile(path string) ([sha256.Size]byte, error) {
+	var sum [sha256.Size]byte
+	file, err := os.Open(path)
+	if err != nil {
+		return sum, err
+	}
+	defer file.Close()
+
+	h := sha256.New()
+	if _, err := io.Copy(h, file); err != nil {
+		return sum, err
+	}
+	copy(sum[:], h.Sum(nil))
+	return sum, nil
+}
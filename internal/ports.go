@@ -0,0 +1,114 @@
+package internal
+
+import (
+	"bufio"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// waitForPorts blocks until every configured port can be bound, so the new
+// instance doesn't fail with "address already in use" while the old
+// listener is still closing. Ports passed to the app as sockets are held
+// by goober itself and never free up, so they are skipped.
+func (r *Runner) waitForPorts() error {
+	for _, port := range r.ports {
+		if r.socketPorts[port] || portFree(port) {
+			continue
+		}
+
+		started := time.Now()
+		holder := "unknown process"
+		if pid, ok := portOwner(port); ok {
+			holder = fmt.Sprintf("PID %d", pid)
+		}
+		r.log(fmt.Sprintf("Waiting for port %d to be released (held by %s)...", port, holder), false)
+
+		deadline := started.Add(r.portTimeout)
+		for !portFree(port) {
+			if time.Now().After(deadline) {
+				if pid, ok := portOwner(port); ok {
+					holder = fmt.Sprintf("PID %d", pid)
+				}
+				return fmt.Errorf("port %d still in use by %s after %s", port, holder, r.portTimeout)
+			}
+			time.Sleep(50 * time.Millisecond)
+		}
+		r.log(fmt.Sprintf("Port %d free after %s", port, time.Since(started).Round(time.Millisecond)), false)
+	}
+	return nil
+}
+
+// portFree reports whether a TCP listener could be opened on port
+func portFree(port int) bool {
+	ln, err := net.Listen("tcp", ":"+strconv.Itoa(port))
+	if err != nil {
+		return false
+	}
+	ln.Close()
+	return true
+}
+
+// portOwner finds the PID listening on port by matching the socket inode
+// from /proc/net/tcp against the open file descriptors in /proc. It only
+// works on Linux, and only for processes we're allowed to inspect.
+func portOwner(port int) (int, bool) {
+	inodes := map[string]bool{}
+	for _, table := range []string{"/proc/net/tcp", "/proc/net/tcp6"} {
+		for _, inode := range listeningInodes(table, port) {
+			inodes[inode] = true
+		}
+	}
+	if len(inodes) == 0 {
+		return 0, false
+	}
+
+	fds, _ := filepath.Glob("/proc/[0-9]*/fd/*")
+	for _, fd := range fds {
+		link, err := os.Readlink(fd)
+		if err != nil || !strings.HasPrefix(link, "socket:[") {
+			continue
+		}
+		if inodes[strings.TrimSuffix(strings.TrimPrefix(link, "socket:["), "]")] {
+			pid, err := strconv.Atoi(strings.Split(fd, "/")[2])
+			if err == nil {
+				return pid, true
+			}
+		}
+	}
+	return 0, false
+}
+
+// listeningInodes returns the inodes of sockets in a /proc/net/tcp table
+// that are listening on port
+func listeningInodes(table string, port int) []string {
+	file, err := os.Open(table)
+	if err != nil {
+		return nil
+	}
+	defer file.Close()
+
+	const stateListen = "0A"
+	var inodes []string
+	scanner := bufio.NewScanner(file)
+	scanner.Scan() // header
+	for scanner.Scan() {
+		// sl local_address rem_address st tx_queue:rx_queue tr:tm->when retrnsmt uid timeout inode
+		fields := strings.Fields(scanner.Text())
+		if len(fields) < 10 || fields[3] != stateListen {
+			continue
+		}
+		_, hexPort, ok := strings.Cut(fields[1], ":")
+		if !ok {
+			continue
+		}
+		if p, err := strconv.ParseInt(hexPort, 16, 32); err == nil && int(p) == port {
+			inodes = append(inodes, fields[9])
+		}
+	}
+	return inodes
+}
@@ -0,0 +1,25 @@
+//go:build windows
+
+package internal
+
+import (
+	"os"
+	"os/exec"
+	"syscall"
+)
+
+// setProcessGroup starts cmd in a new process group
+func setProcessGroup(cmd *exec.Cmd) {
+	cmd.SysProcAttr = &syscall.SysProcAttr{CreationFlags: syscall.CREATE_NEW_PROCESS_GROUP}
+}
+
+// signalProcessGroup signals the process; Windows can only deliver kill,
+// so other signals fail and the caller falls back to killProcessGroup
+func signalProcessGroup(p *os.Process, sig syscall.Signal) error {
+	return p.Signal(sig)
+}
+
+// killProcessGroup terminates the process
+func killProcessGroup(p *os.Process) error {
+	return p.Kill()
+}
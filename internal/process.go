@@ -0,0 +1,192 @@
+package internal
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os/exec"
+	"time"
+)
+
+// Restart policies for when the app exits on its own
+const (
+	RestartNever     = "never"
+	RestartOnFailure = "on-failure"
+	RestartAlways    = "always"
+)
+
+// RestartPolicy decides whether and how soon a crashed app is started again.
+// The delay doubles with every consecutive crash up to MaxBackoff, and after
+// MaxAttempts crashes in a row goober waits for the next file change.
+type RestartPolicy struct {
+	Policy      string        `toml:"policy" yaml:"policy"`
+	Backoff     time.Duration `toml:"backoff" yaml:"backoff"`
+	MaxBackoff  time.Duration `toml:"max_backoff" yaml:"max_backoff"`
+	MaxAttempts int           `toml:"max_attempts" yaml:"max_attempts"`
+}
+
+// stableUptime is how long the app has to run before a crash no longer
+// counts towards a crash loop
+const stableUptime = 10 * time.Second
+
+// ParseRestartPolicy validates a restart policy name
+func ParseRestartPolicy(name string) (string, error) {
+	switch name {
+	case "", RestartNever:
+		return RestartNever, nil
+	case RestartOnFailure, RestartAlways:
+		return name, nil
+	}
+	return "", fmt.Errorf("unsupported restart policy %q (use never, on-failure or always)", name)
+}
+
+// shouldRestart reports whether the policy restarts after event
+func (p RestartPolicy) shouldRestart(event ExitEvent) bool {
+	switch p.Policy {
+	case RestartAlways:
+		return true
+	case RestartOnFailure:
+		return event.Failed()
+	}
+	return false
+}
+
+// delay returns the backoff before restart attempt n, counting from zero
+func (p RestartPolicy) delay(n int) time.Duration {
+	backoff, limit := p.Backoff, p.MaxBackoff
+	if backoff <= 0 {
+		backoff = time.Second
+	}
+	if limit <= 0 {
+		limit = 30 * time.Second
+	}
+	for i := 0; i < n && backoff < limit; i++ {
+		backoff *= 2
+	}
+	if backoff > limit {
+		backoff = limit
+	}
+	return backoff
+}
+
+// ExitEvent describes an app instance that exited without being stopped
+type ExitEvent struct {
+	Code   int    // exit code, or -1 when killed by a signal
+	Signal string // e.g. "signal: segmentation fault" when killed by a signal
+	Uptime time.Duration
+}
+
+// Failed reports whether the exit counts as a crash
+func (e ExitEvent) Failed() bool {
+	return e.Code != 0
+}
+
+func (e ExitEvent) String() string {
+	uptime := e.Uptime.Round(100 * time.Millisecond)
+	if e.Signal != "" {
+		return fmt.Sprintf("App exited (%s) after %s", e.Signal, uptime)
+	}
+	return fmt.Sprintf("App exited with code %d after %s", e.Code, uptime)
+}
+
+// process is a running app instance
+type process struct {
+	cmd     *exec.Cmd
+	outputs []io.Closer
+	started time.Time
+	exited  chan struct{}
+	ctx     context.Context
+	cancel  context.CancelFunc
+}
+
+func newProcess(cmd *exec.Cmd, outputs ...io.Closer) *process {
+	ctx, cancel := context.WithCancel(context.Background())
+	return &process{
+		cmd:     cmd,
+		outputs: outputs,
+		started: time.Now(),
+		exited:  make(chan struct{}),
+		ctx:     ctx,
+		cancel:  cancel,
+	}
+}
+
+// monitor waits for proc to exit. Exits caused by Stop are expected; any
+// other exit is reported and handed to the restart policy.
+func (r *Runner) monitor(proc *process) {
+	proc.cmd.Wait()
+	for _, output := range proc.outputs {
+		output.Close()
+	}
+	close(proc.exited)
+
+	r.mu.Lock()
+	if r.proc != proc {
+		// Stopped on purpose
+		r.mu.Unlock()
+		return
+	}
+	r.proc = nil
+	proc.cancel()
+	if r.proxy != nil {
+		r.proxy.Restarting()
+	}
+	r.mu.Unlock()
+
+	event := ExitEvent{Code: proc.cmd.ProcessState.ExitCode(), Uptime: time.Since(proc.started)}
+	if event.Code == -1 {
+		event.Signal = proc.cmd.ProcessState.String()
+	}
+	if r.exitCallback != nil {
+		r.exitCallback(event)
+	} else {
+		r.log(event.String(), event.Failed())
+	}
+
+	r.scheduleRestart(event)
+}
+
+// scheduleRestart starts the app again after a backoff if the policy says so
+func (r *Runner) scheduleRestart(event ExitEvent) {
+	if !r.restartPolicy.shouldRestart(event) {
+		return
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if event.Uptime >= stableUptime {
+		r.crashes = 0
+	}
+	if max := r.restartPolicy.MaxAttempts; max > 0 && r.crashes >= max {
+		// The first exit came before any restart attempt
+		r.log(fmt.Sprintf("Crash loop: app exited %d times in a row, waiting for changes before restarting", r.crashes+1), true)
+		return
+	}
+
+	delay := r.restartPolicy.delay(r.crashes)
+	r.crashes++
+	r.log(fmt.Sprintf("Restarting app in %s (attempt %d)", delay, r.crashes), false)
+
+	var timer *time.Timer
+	timer = time.AfterFunc(delay, func() {
+		r.mu.Lock()
+		defer r.mu.Unlock()
+		if r.crashTimer != timer || r.proc != nil {
+			return
+		}
+		r.crashTimer = nil
+		r.launchLocked()
+	})
+	r.crashTimer = timer
+}
+
+// resetCrashes cancels a pending crash restart and clears the crash count;
+// r.mu must be held
+func (r *Runner) resetCrashes() {
+	if r.crashTimer != nil {
+		r.crashTimer.Stop()
+		r.crashTimer = nil
+	}
+	r.crashes = 0
+}
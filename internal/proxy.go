@@ -0,0 +1,307 @@
+package internal
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httputil"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// proxyPrefix is the path under which the proxy serves its own endpoints
+const proxyPrefix = "/__goober/"
+
+// reloadScript is injected into HTML pages; it reloads the page when the
+// proxy announces that a new instance of the app is ready
+const reloadScript = `<script>(function(){` +
+	`var es=new EventSource("` + proxyPrefix + `events");` +
+	`es.addEventListener("reload",function(){location.reload()});` +
+	`})();</script>`
+
+// Proxy states
+const (
+	proxyRestarting = iota // no instance ready yet; hold requests
+	proxyReady             // forward requests to the app
+	proxyFailed            // the build is broken; show the errors
+)
+
+// Proxy is a reverse proxy in front of the app that refreshes connected
+// browsers after every successful restart. While the app is restarting it
+// holds incoming requests and forwards them once the new instance is ready.
+type Proxy struct {
+	listen string
+	target *url.URL
+	proxy  *httputil.ReverseProxy
+
+	holdTimeout time.Duration
+	holdSlots   chan struct{}
+
+	mu          sync.Mutex
+	state       int
+	changed     chan struct{} // closed on every state change
+	buildOutput string
+	diagnostics []Diagnostic
+	serving     bool // a previous build is still running despite the failure
+	clients     map[chan string]struct{}
+}
+
+// NewProxy creates a proxy listening on listen and forwarding to target.
+// Both accept a bare ":port" for localhost.
+func NewProxy(listen, target string) (*Proxy, error) {
+	targetURL, err := parseTarget(target)
+	if err != nil {
+		return nil, err
+	}
+
+	p := &Proxy{
+		listen:      listen,
+		target:      targetURL,
+		holdTimeout: 30 * time.Second,
+		holdSlots:   make(chan struct{}, 100),
+		state:       proxyRestarting,
+		changed:     make(chan struct{}),
+		clients:     map[chan string]struct{}{},
+	}
+	p.proxy = httputil.NewSingleHostReverseProxy(targetURL)
+	director := p.proxy.Director
+	p.proxy.Director = func(req *http.Request) {
+		director(req)
+		// Ask for an uncompressed body so the reload script can be injected
+		req.Header.Del("Accept-Encoding")
+	}
+	p.proxy.ModifyResponse = injectReloadScript
+	return p, nil
+}
+
+// parseTarget turns ":8080" or "localhost:8080" into an http URL
+func parseTarget(target string) (*url.URL, error) {
+	if strings.HasPrefix(target, ":") {
+		target = "localhost" + target
+	}
+	if !strings.Contains(target, "://") {
+		target = "http://" + target
+	}
+	u, err := url.Parse(target)
+	if err != nil {
+		return nil, fmt.Errorf("invalid proxy target: %w", err)
+	}
+	return u, nil
+}
+
+// SetHold limits how long requests are held during a restart and how many
+// may be waiting at once
+func (p *Proxy) SetHold(timeout time.Duration, queueSize int) {
+	if timeout > 0 {
+		p.holdTimeout = timeout
+	}
+	if queueSize > 0 {
+		p.holdSlots = make(chan struct{}, queueSize)
+	}
+}
+
+// Target returns the URL the proxy forwards to
+func (p *Proxy) Target() *url.URL {
+	return p.target
+}
+
+// ListenAndServe serves the proxy until it fails
+func (p *Proxy) ListenAndServe() error {
+	return http.ListenAndServe(p.listen, p)
+}
+
+func (p *Proxy) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	if req.URL.Path == proxyPrefix+"events" {
+		p.serveEvents(w, req)
+		return
+	}
+
+	for {
+		p.mu.Lock()
+		state, changed, serving := p.state, p.changed, p.serving
+		output, diags := p.buildOutput, p.diagnostics
+		p.mu.Unlock()
+
+		switch state {
+		case proxyReady:
+			p.proxy.ServeHTTP(w, req)
+			return
+		case proxyFailed:
+			// Pages show the errors; other requests keep going to the
+			// previous build when there is one
+			if serving && !acceptsHTML(req) {
+				p.proxy.ServeHTTP(w, req)
+			} else {
+				serveBuildError(w, output, diags)
+			}
+			return
+		}
+
+		if !p.hold(w, req, changed) {
+			return
+		}
+	}
+}
+
+// hold waits for the proxy state to change while the app restarts. It
+// reports false if the request was answered or abandoned instead.
+func (p *Proxy) hold(w http.ResponseWriter, req *http.Request, changed <-chan struct{}) bool {
+	select {
+	case p.holdSlots <- struct{}{}:
+		defer func() { <-p.holdSlots }()
+	default:
+		http.Error(w, "goober: too many requests waiting for the app to restart", http.StatusServiceUnavailable)
+		return false
+	}
+
+	timer := time.NewTimer(p.holdTimeout)
+	defer timer.Stop()
+
+	select {
+	case <-changed:
+		return true
+	case <-timer.C:
+		http.Error(w, fmt.Sprintf("goober: app not ready after %s", p.holdTimeout), http.StatusGatewayTimeout)
+	case <-req.Context().Done():
+	}
+	return false
+}
+
+// setState switches the proxy state and wakes every held request
+func (p *Proxy) setState(state int) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.state = state
+	if state != proxyFailed {
+		p.buildOutput, p.diagnostics, p.serving = "", nil, false
+	}
+	close(p.changed)
+	p.changed = make(chan struct{})
+}
+
+// Restarting makes the proxy hold requests until the next Ready
+func (p *Proxy) Restarting() {
+	p.setState(proxyRestarting)
+}
+
+// BuildFailed shows the compiler errors to browsers until the next
+// successful restart. When serving is set, a previous build is still
+// running and non-page requests keep going to it.
+func (p *Proxy) BuildFailed(output string, serving bool) {
+	p.mu.Lock()
+	p.buildOutput = output
+	p.diagnostics = ParseDiagnostics(output)
+	p.serving = serving
+	p.mu.Unlock()
+
+	p.setState(proxyFailed)
+	// Pages already open show the error overlay right away
+	p.Reload()
+}
+
+// Ready forwards held and new requests to the app and reloads connected
+// browsers, returning how many were notified
+func (p *Proxy) Ready() int {
+	p.setState(proxyReady)
+	return p.Reload()
+}
+
+// acceptsHTML reports whether req comes from a browser asking for a page
+func acceptsHTML(req *http.Request) bool {
+	return strings.Contains(req.Header.Get("Accept"), "text/html")
+}
+
+// serveEvents streams reload notifications to a browser over SSE
+func (p *Proxy) serveEvents(w http.ResponseWriter, req *http.Request) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "streaming unsupported", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	flusher.Flush()
+
+	events := make(chan string, 1)
+	p.mu.Lock()
+	p.clients[events] = struct{}{}
+	p.mu.Unlock()
+	defer func() {
+		p.mu.Lock()
+		delete(p.clients, events)
+		p.mu.Unlock()
+	}()
+
+	keepAlive := time.NewTicker(15 * time.Second)
+	defer keepAlive.Stop()
+	for {
+		select {
+		case <-req.Context().Done():
+			return
+		case <-keepAlive.C:
+			fmt.Fprint(w, ": keep-alive\n\n")
+		case event := <-events:
+			fmt.Fprintf(w, "event: %s\ndata: %d\n\n", event, time.Now().UnixMilli())
+		}
+		flusher.Flush()
+	}
+}
+
+// Reload tells every connected browser to reload and returns how many
+// were notified
+func (p *Proxy) Reload() int {
+	return p.broadcast("reload")
+}
+
+func (p *Proxy) broadcast(event string) int {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	for client := range p.clients {
+		select {
+		case client <- event:
+		default:
+			// A notification is already pending for this client
+		}
+	}
+	return len(p.clients)
+}
+
+// injectReloadScript adds the reload script to HTML responses
+func injectReloadScript(resp *http.Response) error {
+	if !strings.HasPrefix(resp.Header.Get("Content-Type"), "text/html") || resp.Header.Get("Content-Encoding") != "" {
+		return nil
+	}
+
+	body, err := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if err != nil {
+		return err
+	}
+
+	body = insertBeforeBodyEnd(body, []byte(reloadScript))
+	resp.Body = io.NopCloser(bytes.NewReader(body))
+	resp.ContentLength = int64(len(body))
+	resp.Header.Set("Content-Length", strconv.Itoa(len(body)))
+	return nil
+}
+
+// insertBeforeBodyEnd puts snippet right before </body>, or at the end of
+// the document if there is no closing body tag
+func insertBeforeBodyEnd(page, snippet []byte) []byte {
+	i := bytes.LastIndex(bytes.ToLower(page), []byte("</body>"))
+	if i < 0 {
+		return append(page, snippet...)
+	}
+	out := make([]byte, 0, len(page)+len(snippet))
+	out = append(out, page[:i]...)
+	out = append(out, snippet...)
+	return append(out, page[i:]...)
+}
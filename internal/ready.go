@@ -0,0 +1,154 @@
+package internal
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ReadyCheck describes how to tell that a freshly started app is ready to
+// serve. Every configured probe has to pass; with none, the app counts as
+// ready as soon as it starts.
+type ReadyCheck struct {
+	HTTP     string        `toml:"http" yaml:"http"` // URL that must answer GET with a 2xx
+	TCP      string        `toml:"tcp" yaml:"tcp"`   // address that must accept connections
+	Log      string        `toml:"log" yaml:"log"`   // regexp an output line must match
+	Timeout  time.Duration `toml:"timeout" yaml:"timeout"`
+	Interval time.Duration `toml:"interval" yaml:"interval"`
+}
+
+// HasProbes reports whether any probe is configured
+func (c ReadyCheck) HasProbes() bool {
+	return c.HTTP != "" || c.TCP != "" || c.Log != ""
+}
+
+// readyProbe tracks readiness of one app instance
+type readyProbe struct {
+	check   ReadyCheck
+	logRe   *regexp.Regexp
+	matched chan struct{}
+	once    sync.Once
+}
+
+func newReadyProbe(check ReadyCheck) (*readyProbe, error) {
+	p := &readyProbe{check: check, matched: make(chan struct{})}
+	if check.Log != "" {
+		re, err := regexp.Compile(check.Log)
+		if err != nil {
+			return nil, fmt.Errorf("invalid ready log pattern: %w", err)
+		}
+		p.logRe = re
+	}
+	if p.check.Timeout <= 0 {
+		p.check.Timeout = 30 * time.Second
+	}
+	if p.check.Interval <= 0 {
+		p.check.Interval = 200 * time.Millisecond
+	}
+	return p, nil
+}
+
+// observe checks an output line against the log pattern
+func (p *readyProbe) observe(line string) {
+	if p.logRe != nil && p.logRe.MatchString(line) {
+		p.once.Do(func() { close(p.matched) })
+	}
+}
+
+// wait blocks until every probe passes, the timeout expires or ctx is done
+func (p *readyProbe) wait(ctx context.Context) error {
+	ctx, cancel := context.WithTimeout(ctx, p.check.Timeout)
+	defer cancel()
+
+	if p.logRe != nil {
+		select {
+		case <-p.matched:
+		case <-ctx.Done():
+			return p.failure(ctx, fmt.Errorf("no output line matched %q", p.check.Log))
+		}
+	}
+
+	for _, probe := range []func(context.Context) error{p.probeTCP, p.probeHTTP} {
+		for {
+			err := probe(ctx)
+			if err == nil {
+				break
+			}
+			select {
+			case <-time.After(p.check.Interval):
+			case <-ctx.Done():
+				return p.failure(ctx, err)
+			}
+		}
+	}
+	return nil
+}
+
+// failure wraps the last probe error, passing cancellation through as is
+func (p *readyProbe) failure(ctx context.Context, err error) error {
+	if ctx.Err() == context.Canceled {
+		return context.Canceled
+	}
+	return fmt.Errorf("not ready after %s: %w", p.check.Timeout, err)
+}
+
+func (p *readyProbe) probeTCP(ctx context.Context) error {
+	if p.check.TCP == "" {
+		return nil
+	}
+	addr := p.check.TCP
+	if strings.HasPrefix(addr, ":") {
+		addr = "localhost" + addr
+	}
+	dialer := net.Dialer{Timeout: time.Second}
+	conn, err := dialer.DialContext(ctx, "tcp", addr)
+	if err != nil {
+		return err
+	}
+	return conn.Close()
+}
+
+func (p *readyProbe) probeHTTP(ctx context.Context) error {
+	if p.check.HTTP == "" {
+		return nil
+	}
+	ctx, cancel := context.WithTimeout(ctx, time.Second)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, p.check.HTTP, nil)
+	if err != nil {
+		return err
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("GET %s returned %s", p.check.HTTP, resp.Status)
+	}
+	return nil
+}
+
+// awaitReady reports when the app started at started becomes ready
+func (r *Runner) awaitReady(ctx context.Context, probe *readyProbe, started time.Time) {
+	err := probe.wait(ctx)
+	switch {
+	case err == context.Canceled || ctx.Err() == context.Canceled:
+		// Stopped before it got ready
+	case err != nil:
+		r.log(fmt.Sprintf("App %v", err), true)
+	default:
+		r.log(fmt.Sprintf("Ready in %s", time.Since(started).Round(100*time.Millisecond)), false)
+		if r.proxy != nil {
+			if n := r.proxy.Ready(); n > 0 {
+				r.log(fmt.Sprintf("Reloading %d browser(s)", n), false)
+			}
+		}
+	}
+}
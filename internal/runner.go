@@ -2,29 +2,141 @@ package internal
 
 import (
 	"bufio"
-	"bytes"
+	"context"
+	"errors"
 	"fmt"
 	"io"
 	"os"
-	"os/exec"
 	"strings"
 	"sync"
+	"syscall"
+	"time"
 )
 
+// ErrBuildSuperseded is returned by Start when a newer restart cancelled the build
+var ErrBuildSuperseded = errors.New("build superseded")
+
+// CommandError is returned when a build command fails. It keeps the
+// command's stderr so it can be shown somewhere other than the log.
+type CommandError struct {
+	Output string
+	Err    error
+}
+
+func (e *CommandError) Error() string { return e.Err.Error() }
+func (e *CommandError) Unwrap() error { return e.Err }
+
 type LogCallback func(message string, isError bool)
 type RestartCallback func()
+type ExitCallback func(event ExitEvent)
+type DiagnosticsCallback func(diags []Diagnostic)
 
 type Runner struct {
-	buildCmd        string
-	runCmd          string
-	proc            *exec.Cmd
-	mu              sync.Mutex
-	logCallback     LogCallback
-	restartCallback RestartCallback
+	stages            []Stage
+	runCmd            string
+	env               []string
+	shell             bool
+	stopSignal        syscall.Signal
+	killTimeout       time.Duration
+	ports             []int
+	portTimeout       time.Duration
+	hooks             Hooks
+	readyCheck        ReadyCheck
+	restartPolicy     RestartPolicy
+	proxy             *Proxy
+	tests             TestConfig
+	testFilter        string
+	sockets           []*os.File
+	socketPorts       map[int]bool // TCP ports of sockets, which goober itself holds open
+	proc              *process
+	crashes           int
+	crashTimer        *time.Timer
+	mu                sync.Mutex
+	buildMu           sync.Mutex
+	cancelBuild       context.CancelFunc
+	logCallback       LogCallback
+	restartCallback   RestartCallback
+	exitCallback      ExitCallback
+	diagCallback      DiagnosticsCallback
+	testCallback      TestCallback
+	testEventCallback TestEventCallback
+	stageCallback     StageCallback
 }
 
+// NewRunner creates a runner whose pipeline is the single build command;
+// SetStages replaces it with more stages
 func NewRunner(build, run string) *Runner {
-	return &Runner{buildCmd: build, runCmd: run}
+	var stages []Stage
+	if build != "" {
+		stages = []Stage{{Name: "build", Phase: PhaseBuild, Cmd: build}}
+	}
+	return &Runner{
+		stages:      stages,
+		runCmd:      run,
+		stopSignal:  syscall.SIGINT,
+		killTimeout: 5 * time.Second,
+		portTimeout: 10 * time.Second,
+	}
+}
+
+// SetEnv sets extra environment variables for the build, hooks and app
+func (r *Runner) SetEnv(env map[string]string) {
+	r.env = r.env[:0]
+	for key, value := range env {
+		r.env = append(r.env, key+"="+value)
+	}
+}
+
+// SetShell makes commands run through the system shell instead of being
+// split into words by goober, which allows pipelines and && chains
+func (r *Runner) SetShell(shell bool) {
+	r.shell = shell
+}
+
+// SetStopSignal sets the signal sent to the app's process group on stop
+func (r *Runner) SetStopSignal(sig syscall.Signal) {
+	r.stopSignal = sig
+}
+
+// SetKillTimeout sets how long to wait after the stop signal before
+// killing the app's process group
+func (r *Runner) SetKillTimeout(timeout time.Duration) {
+	r.killTimeout = timeout
+}
+
+// SetPorts sets the ports the app listens on. Before starting, goober waits
+// up to timeout for each of them to be released by the previous instance.
+func (r *Runner) SetPorts(ports []int, timeout time.Duration) {
+	r.ports = ports
+	r.portTimeout = timeout
+}
+
+// SetReadyCheck sets the probes that decide when a started app is ready
+func (r *Runner) SetReadyCheck(check ReadyCheck) {
+	r.readyCheck = check
+}
+
+// SetDiagnosticsCallback sets the callback that receives the diagnostics
+// of every finished build; a successful build reports none
+func (r *Runner) SetDiagnosticsCallback(callback DiagnosticsCallback) {
+	r.diagCallback = callback
+}
+
+// SetRestartPolicy sets what happens when the app exits on its own
+func (r *Runner) SetRestartPolicy(policy RestartPolicy) {
+	r.restartPolicy = policy
+}
+
+// SetProxy puts a live-reload proxy in front of the app. Browsers connected
+// through it reload whenever a new instance becomes ready.
+func (r *Runner) SetProxy(proxy *Proxy) {
+	r.proxy = proxy
+}
+
+// SetHooks sets the commands run after the app stops. The build hooks are
+// part of the pipeline, see Config.Pipeline.
+func (r *Runner) SetHooks(hooks Hooks) {
+	r.hooks = hooks
 }
 
 func (r *Runner) SetLogCallback(callback LogCallback) {
@@ -35,6 +147,11 @@ func (r *Runner) SetRestartCallback(callback RestartCallback) {
 	r.restartCallback = callback
 }
 
+// SetExitCallback sets the callback for when the app exits on its own
+func (r *Runner) SetExitCallback(callback ExitCallback) {
+	r.exitCallback = callback
+}
+
 func (r *Runner) log(message string, isError bool) {
 	if r.logCallback != nil {
 		r.logCallback(message, isError)
@@ -49,50 +166,197 @@ func (r *Runner) log(message string, isError bool) {
 
 // Build and run the app
 func (r *Runner) Start() error {
+	return r.StartContext(context.Background())
+}
+
+// StartContext builds and runs the app, abandoning the build with
+// ErrBuildSuperseded if ctx is cancelled first. A running instance is only
+// stopped after the build succeeds, so a broken build leaves it serving.
+func (r *Runner) StartContext(ctx context.Context) error {
+	return r.start(ctx, nil)
+}
+
+// start is StartContext with trigger commands run ahead of the pipeline
+func (r *Runner) start(ctx context.Context, triggers []Trigger) error {
 	r.mu.Lock()
 	defer r.mu.Unlock()
 
-	r.log("Building...", false)
-	if err := r.runCommand(r.buildCmd); err != nil {
-		r.log(fmt.Sprintf("Build failed: %v", err), true)
+	if err := r.build(ctx, triggers); err != nil {
+		if errors.Is(err, ErrBuildSuperseded) {
+			r.log("Build superseded by newer changes", false)
+			return err
+		}
+		if r.proc != nil {
+			r.log(fmt.Sprintf("Build failed (serving previous build): %v", err), true)
+		} else {
+			r.log(fmt.Sprintf("Build failed: %v", err), true)
+		}
+		if r.proxy != nil {
+			r.proxy.BuildFailed(buildOutput(err), r.proc != nil)
+		}
+		if r.diagCallback != nil {
+			r.diagCallback(ParseDiagnostics(buildOutput(err)))
+		}
+		return err
+	}
+	if r.diagCallback != nil {
+		r.diagCallback(nil)
+	}
+
+	// Only swap out the previous instance once the new build is good
+	return r.swapLocked(ctx)
+}
+
+// swapLocked stops the running instance, runs the pre-run stages and starts
+// the new one; r.mu must be held
+func (r *Runner) swapLocked(ctx context.Context) error {
+	r.resetCrashes()
+	if r.proxy != nil {
+		r.proxy.Restarting()
+	}
+	r.stopLocked()
+
+	if err := r.runStages(ctx, PhasePreRun); err != nil {
+		if !errors.Is(err, ErrBuildSuperseded) {
+			r.log(fmt.Sprintf("Failed to start app: %v", err), true)
+			if r.proxy != nil {
+				r.proxy.BuildFailed(buildOutput(err), false)
+			}
+		}
+		return err
+	}
+
+	return r.launchLocked()
+}
+
+// launchLocked starts the already built app; r.mu must be held
+func (r *Runner) launchLocked() error {
+	if err := r.waitForPorts(); err != nil {
+		r.log(fmt.Sprintf("Failed to start app: %v", err), true)
 		return err
 	}
 
-	r.log("Build successful", false)
 	r.log("Starting app...", false)
 	
-	parts := strings.Split(r.runCmd, " ")
-	cmd := exec.Command(parts[0], parts[1:]...)
-	
-	// Create pipes for stdout and stderr
-	stdout, err := cmd.StdoutPipe()
+	cmd, err := r.command(context.Background(), r.runCmd)
 	if err != nil {
+		r.log(fmt.Sprintf("Failed to start app: %v", err), true)
 		return err
 	}
-	stderr, err := cmd.StderrPipe()
+	if err := r.attachSockets(cmd); err != nil {
+		r.log(fmt.Sprintf("Failed to start app: %v", err), true)
+		return err
+	}
+	probe, err := newReadyProbe(r.readyCheck)
 	if err != nil {
+		r.log(fmt.Sprintf("Failed to start app: %v", err), true)
 		return err
 	}
 	
+	// Create pipes for stdout and stderr. Wait copies into them, so the
+	// last lines (like a panic trace) are read before the exit is reported.
+	stdout, stdoutW := io.Pipe()
+	stderr, stderrW := io.Pipe()
+	cmd.Stdout = stdoutW
+	cmd.Stderr = stderrW
+	cmd.WaitDelay = time.Second
+	
 	if err := cmd.Start(); err != nil {
 		r.log(fmt.Sprintf("Failed to start app: %v", err), true)
 		return err
 	}
 	
-	r.proc = cmd
+	proc := newProcess(cmd, stdoutW, stderrW)
+	r.proc = proc
 	
 	// Stream output
-	go r.streamOutput(stdout, false)
-	go r.streamOutput(stderr, true)
+	go r.streamOutput(stdout, false, probe)
+	go r.streamOutput(stderr, true, probe)
+	go r.awaitReady(proc.ctx, probe, proc.started)
+	go r.monitor(proc)
 	
 	return nil
 }
 
-// streamOutput reads from a pipe and sends output to the log callback
-func (r *Runner) streamOutput(pipe io.ReadCloser, isError bool) {
+// build runs the trigger commands, then the pre-build, build and
+// post-build stages
+func (r *Runner) build(ctx context.Context, triggers []Trigger) error {
+	r.resetStages()
+	r.log("Building...", false)
+	if err := r.runTriggers(ctx, triggers); err != nil {
+		return err
+	}
+	if err := r.runStages(ctx, PhasePreBuild, PhaseBuild, PhasePostBuild); err != nil {
+		return err
+	}
+
+	r.log("Build successful", false)
+	return nil
+}
+
+// Restart rebuilds the app and swaps in the new instance. A build still in
+// progress from an earlier restart is killed rather than left to finish.
+func (r *Runner) Restart() error {
+	return r.RestartChanged(nil)
+}
+
+// RestartChanged is Restart after files changed. With the test stage
+// enabled, the packages affected by files are tested in the background
+// once the new instance is running; no files means all of them.
+func (r *Runner) RestartChanged(files []string) error {
+	return r.restart(files, nil)
+}
+
+// restart is RestartChanged with trigger commands run before the build
+func (r *Runner) restart(files []string, triggers []Trigger) error {
+	ctx := r.supersedeBuild()
+
+	// Notify about restart
+	if r.restartCallback != nil {
+		r.restartCallback()
+	}
+
+	if err := r.start(ctx, triggers); err != nil {
+		return err
+	}
+	if r.tests.Enabled {
+		go r.runTests(ctx, files)
+	}
+	return nil
+}
+
+// Relaunch restarts the app from the current build, without rebuilding
+func (r *Runner) Relaunch() error {
+	if r.restartCallback != nil {
+		r.restartCallback()
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.swapLocked(context.Background())
+}
+
+// supersedeBuild cancels the in-flight build, if any, and returns the
+// context for the next one
+func (r *Runner) supersedeBuild() context.Context {
+	r.buildMu.Lock()
+	defer r.buildMu.Unlock()
+
+	if r.cancelBuild != nil {
+		r.cancelBuild()
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	r.cancelBuild = cancel
+	return ctx
+}
+
+// streamOutput reads from a pipe and sends output to the log callback,
+// letting the readiness probe look at every line
+func (r *Runner) streamOutput(pipe io.ReadCloser, isError bool, probe *readyProbe) {
 	scanner := bufio.NewScanner(pipe)
 	for scanner.Scan() {
 		line := scanner.Text()
+		probe.observe(line)
 		if strings.TrimSpace(line) != "" {
 			r.log(line, isError)
 		}
@@ -102,42 +366,91 @@ func (r *Runner) streamOutput(pipe io.ReadCloser, isError bool) {
 
 // Stop the running app
 func (r *Runner) Stop() {
+	// Kill a build or test run that is still going
+	r.buildMu.Lock()
+	if r.cancelBuild != nil {
+		r.cancelBuild()
+	}
+	r.buildMu.Unlock()
+
 	r.mu.Lock()
 	defer r.mu.Unlock()
 
-	if r.proc == nil || r.proc.Process == nil {
+	r.resetCrashes()
+	r.stopLocked()
+}
+
+// stopLocked stops the running app; r.mu must be held
+func (r *Runner) stopLocked() {
+	if r.proc == nil {
 		return
 	}
+	proc := r.proc
 
 	r.log("Stopping app...", false)
-	if err := r.proc.Process.Signal(os.Interrupt); err != nil {
+	proc.cancel()
+	started := time.Now()
+	if err := signalProcessGroup(proc.cmd.Process, r.stopSignal); err != nil {
 		r.log("Graceful stop failed, killing...", true)
-		r.proc.Process.Kill()
+		killProcessGroup(proc.cmd.Process)
+	}
+
+	select {
+	case <-proc.exited:
+		r.log(fmt.Sprintf("App stopped in %s", time.Since(started).Round(time.Millisecond)), false)
+	case <-time.After(r.killTimeout):
+		r.log(fmt.Sprintf("App ignored %s for %s, killing...", r.stopSignal, r.killTimeout), true)
+		killProcessGroup(proc.cmd.Process)
+		<-proc.exited
+		r.log(fmt.Sprintf("App killed after %s", time.Since(started).Round(time.Millisecond)), false)
 	}
-	r.proc.Wait()
 	r.proc = nil
+
+	if err := r.runHooks(context.Background(), "after_stop", r.hooks.AfterStop); err != nil {
+		r.log(err.Error(), true)
+	}
 }
 
-// Utility to run build commands with output capture
-func (r *Runner) runCommand(cmdStr string) error {
-	parts := strings.Split(cmdStr, " ")
-	cmd := exec.Command(parts[0], parts[1:]...)
-	
-	var stdout, stderr bytes.Buffer
-	cmd.Stdout = &stdout
-	cmd.Stderr = &stderr
-	
-	err := cmd.Run()
-	
-	// Log stdout
-	if stdout.Len() > 0 {
-		r.log(strings.TrimSpace(stdout.String()), false)
+// runHooks runs each hook command in order, stopping at the first failure
+func (r *Runner) runHooks(ctx context.Context, name string, cmds []string) error {
+	for _, cmdStr := range cmds {
+		r.log(fmt.Sprintf("Running %s hook: %s", name, cmdStr), false)
+		if err := r.runCommand(ctx, cmdStr); err != nil {
+			if errors.Is(err, ErrBuildSuperseded) {
+				return err
+			}
+			return fmt.Errorf("%s hook %q: %w", name, cmdStr, err)
+		}
 	}
-	
-	// Log stderr
-	if stderr.Len() > 0 {
-		r.log(strings.TrimSpace(stderr.String()), true)
+	return nil
+}
+
+// environ returns the process environment with the configured overrides applied
+func (r *Runner) environ() []string {
+	return append(os.Environ(), r.env...)
+}
+
+// runCommand runs a hook command with output capture. The command is
+// killed if ctx is cancelled, in which case ErrBuildSuperseded is returned.
+func (r *Runner) runCommand(ctx context.Context, cmdStr string) error {
+	return r.runStage(ctx, Stage{Cmd: cmdStr})
+}
+
+// logLines logs each non-empty line of output as its own entry, after prefix
+func (r *Runner) logLines(prefix, output string, isError bool) {
+	for _, line := range strings.Split(output, "\n") {
+		if strings.TrimSpace(line) != "" {
+			r.log(prefix+strings.TrimRight(line, "\r"), isError)
+		}
 	}
-	
-	return err
+}
+
+// buildOutput returns the compiler output behind a build error, falling
+// back to the error itself
+func buildOutput(err error) string {
+	var cmdErr *CommandError
+	if errors.As(err, &cmdErr) && cmdErr.Output != "" {
+		return cmdErr.Output
+	}
+	return err.Error()
 }
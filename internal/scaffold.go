@@ -0,0 +1,208 @@
+package internal
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// MainPackage is a buildable main package found in the project
+type MainPackage struct {
+	Name string // binary name
+	Path string // package path relative to the project root, e.g. ./cmd/api
+}
+
+// BuildCommand returns the build command for the package, writing into outDir
+func (p MainPackage) BuildCommand(outDir string) string {
+	if p.Path == "." {
+		return fmt.Sprintf("go build -o %s", p.Binary(outDir))
+	}
+	return fmt.Sprintf("go build -o %s %s", p.Binary(outDir), p.Path)
+}
+
+// RunCommand returns the command that runs the built binary
+func (p MainPackage) RunCommand(outDir string) string {
+	return p.Binary(outDir)
+}
+
+// Binary returns the output path of the built binary
+func (p MainPackage) Binary(outDir string) string {
+	return "./" + filepath.ToSlash(filepath.Join(outDir, p.Name))
+}
+
+// ProjectLayout is what goober init learned about the project
+type ProjectLayout struct {
+	Module   string
+	Mains    []MainPackage
+	Binaries []string // executables already present in the tree
+	OutDir   string   // where built binaries should go
+}
+
+// DetectProject inspects dir for go.mod, main packages and built binaries
+func DetectProject(dir string) (ProjectLayout, error) {
+	// Build into bin rather than the project root, so that ignoring the
+	// output can't hide a source directory of the same name, like cmd/api
+	layout := ProjectLayout{OutDir: "bin"}
+
+	module, err := readModulePath(filepath.Join(dir, "go.mod"))
+	if err != nil {
+		return layout, err
+	}
+	layout.Module = module
+
+	if isMainPackage(dir) {
+		name := filepath.Base(module)
+		if name == "." || name == "/" {
+			name = "app"
+		}
+		layout.Mains = append(layout.Mains, MainPackage{Name: name, Path: "."})
+	}
+
+	cmdMains, _ := filepath.Glob(filepath.Join(dir, "cmd", "*", "main.go"))
+	sort.Strings(cmdMains)
+	for _, mainFile := range cmdMains {
+		pkgDir := filepath.Dir(mainFile)
+		if !isMainPackage(pkgDir) {
+			continue
+		}
+		name := filepath.Base(pkgDir)
+		layout.Mains = append(layout.Mains, MainPackage{
+			Name: name,
+			Path: "./" + filepath.ToSlash(filepath.Join("cmd", name)),
+		})
+	}
+
+	layout.Binaries = findBinaries(dir, layout.OutDir)
+
+	return layout, nil
+}
+
+// readModulePath returns the module path declared in a go.mod file
+func readModulePath(goMod string) (string, error) {
+	file, err := os.Open(goMod)
+	if err != nil {
+		return "", fmt.Errorf("no go.mod found: %w", err)
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if strings.HasPrefix(line, "module ") {
+			return strings.Trim(strings.TrimSpace(strings.TrimPrefix(line, "module ")), `"`), nil
+		}
+	}
+	return "", fmt.Errorf("%s has no module directive", goMod)
+}
+
+// isMainPackage reports whether any non-test Go file in dir declares package main
+func isMainPackage(dir string) bool {
+	files, _ := filepath.Glob(filepath.Join(dir, "*.go"))
+	for _, file := range files {
+		if strings.HasSuffix(file, "_test.go") {
+			continue
+		}
+		if packageName(file) == "main" {
+			return true
+		}
+	}
+	return false
+}
+
+// packageName returns the package clause of a Go source file
+func packageName(path string) string {
+	file, err := os.Open(path)
+	if err != nil {
+		return ""
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) >= 2 && fields[0] == "package" {
+			return fields[1]
+		}
+	}
+	return ""
+}
+
+// findBinaries lists executable files in the project root and output directory
+func findBinaries(dir, outDir string) []string {
+	var binaries []string
+	seen := map[string]bool{}
+	for _, sub := range []string{".", outDir} {
+		entries, err := os.ReadDir(filepath.Join(dir, sub))
+		if err != nil {
+			continue
+		}
+		for _, entry := range entries {
+			info, err := entry.Info()
+			if err != nil || !info.Mode().IsRegular() || info.Mode().Perm()&0111 == 0 {
+				continue
+			}
+			if strings.HasSuffix(entry.Name(), ".sh") {
+				continue
+			}
+			rel := filepath.ToSlash(filepath.Join(sub, entry.Name()))
+			if !seen[rel] {
+				seen[rel] = true
+				binaries = append(binaries, rel)
+			}
+		}
+	}
+	return binaries
+}
+
+// ScaffoldConfig renders a starter goober.toml using pkg as the active
+// package; the remaining main packages are listed as commented alternatives.
+func ScaffoldConfig(layout ProjectLayout, pkg MainPackage) string {
+	var b strings.Builder
+
+	fmt.Fprintf(&b, "# goober config for %s\n\n", layout.Module)
+	fmt.Fprintf(&b, "build = %q\n", pkg.BuildCommand(layout.OutDir))
+	fmt.Fprintf(&b, "run = %q\n", pkg.RunCommand(layout.OutDir))
+	for _, other := range layout.Mains {
+		if other == pkg {
+			continue
+		}
+		fmt.Fprintf(&b, "\n# %s\n", other.Path)
+		fmt.Fprintf(&b, "# build = %q\n", other.BuildCommand(layout.OutDir))
+		fmt.Fprintf(&b, "# run = %q\n", other.RunCommand(layout.OutDir))
+	}
+
+	b.WriteString("\ndir = \".\"\n")
+	b.WriteString("debounce = \"750ms\"\n")
+
+	// Patterns are anchored to dir; a bare "api" would match cmd/api too
+	ignore := []string{}
+	if layout.OutDir != "." {
+		ignore = append(ignore, "/"+layout.OutDir)
+	}
+	seen := map[string]bool{}
+	for _, bin := range append(layout.Binaries, strings.TrimPrefix(pkg.Binary(layout.OutDir), "./")) {
+		name := filepath.Base(bin)
+		if filepath.Dir(bin) == "." && !seen[name] {
+			seen[name] = true
+			ignore = append(ignore, "/"+name)
+		}
+	}
+	quoted := make([]string, len(ignore))
+	for i, pattern := range ignore {
+		quoted[i] = fmt.Sprintf("%q", pattern)
+	}
+	fmt.Fprintf(&b, "ignore = [%s]\n", strings.Join(quoted, ", "))
+
+	b.WriteString("\n[env]\n")
+	b.WriteString("# PORT = \"8080\"\n")
+	b.WriteString("\n# Extra pipeline stages, e.g. code generation before the build\n")
+	b.WriteString("# [[stages]]\n")
+	b.WriteString("# name = \"generate\"\n")
+	b.WriteString("# phase = \"pre-build\"\n")
+	b.WriteString("# cmd = \"go generate ./...\"\n")
+
+	return b.String()
+}
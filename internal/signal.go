@@ -0,0 +1,27 @@
+package internal
+
+import (
+	"fmt"
+	"strings"
+	"syscall"
+)
+
+// stopSignals are the signals accepted for stopping the app
+var stopSignals = map[string]syscall.Signal{
+	"SIGTERM": syscall.SIGTERM,
+	"SIGINT":  syscall.SIGINT,
+	"SIGQUIT": syscall.SIGQUIT,
+}
+
+// ParseSignal turns a name like "SIGTERM" or "term" into a stop signal
+func ParseSignal(name string) (syscall.Signal, error) {
+	name = strings.ToUpper(strings.TrimSpace(name))
+	if !strings.HasPrefix(name, "SIG") {
+		name = "SIG" + name
+	}
+	sig, ok := stopSignals[name]
+	if !ok {
+		return 0, fmt.Errorf("unsupported stop signal %q (use SIGTERM, SIGINT or SIGQUIT)", name)
+	}
+	return sig, nil
+}
@@ -0,0 +1,118 @@
+package internal
+
+import (
+	"fmt"
+	"net"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+)
+
+// ListenExecCommand is the hidden goober subcommand that sets LISTEN_PID to
+// its own PID and then execs the app in its place, since the app's PID
+// isn't known before it starts.
+const ListenExecCommand = "__listen-exec"
+
+// listenFdsStart is the first file descriptor used for passed sockets
+const listenFdsStart = 3
+
+// SetSockets opens the listening sockets that are handed to every app
+// instance via LISTEN_FDS, so connections queue in the kernel while the app
+// restarts instead of being refused. Addresses are "host:port" for TCP or
+// "unix:/path" for Unix sockets.
+func (r *Runner) SetSockets(addrs []string) error {
+	if len(addrs) > 0 && !socketHandoffSupported {
+		return fmt.Errorf("socket handoff is not supported on this platform")
+	}
+
+	var files []*os.File
+	ports := map[int]bool{}
+	for _, addr := range addrs {
+		network := "tcp"
+		if path, ok := strings.CutPrefix(addr, "unix:"); ok {
+			network, addr = "unix", path
+			if err := removeStaleSocket(addr); err != nil {
+				closeAll(files)
+				return err
+			}
+		}
+
+		ln, err := net.Listen(network, addr)
+		if err != nil {
+			closeAll(files)
+			return fmt.Errorf("listening on %s: %w", addr, err)
+		}
+		if tcp, ok := ln.Addr().(*net.TCPAddr); ok {
+			ports[tcp.Port] = true
+		}
+		if unix, ok := ln.(*net.UnixListener); ok {
+			// Keep the socket path around after our copy of the listener closes
+			unix.SetUnlinkOnClose(false)
+		}
+		// The app gets a duplicate of the listener's descriptor
+		file, err := ln.(interface{ File() (*os.File, error) }).File()
+		ln.Close()
+		if err != nil {
+			closeAll(files)
+			return fmt.Errorf("listening on %s: %w", addr, err)
+		}
+		files = append(files, file)
+	}
+
+	r.sockets = files
+	r.socketPorts = ports
+	return nil
+}
+
+// removeStaleSocket removes a Unix socket left behind at path by an earlier
+// run. Anything else at path is left alone, so a typo can't delete a file.
+func removeStaleSocket(path string) error {
+	info, err := os.Lstat(path)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("listening on %s: %w", path, err)
+	}
+	if info.Mode()&os.ModeSocket == 0 {
+		return fmt.Errorf("listening on %s: file exists and is not a socket", path)
+	}
+	return os.Remove(path)
+}
+
+// attachSockets passes the listening sockets to cmd, routing it through
+// ListenExecCommand so LISTEN_PID matches the app's PID
+func (r *Runner) attachSockets(cmd *exec.Cmd) error {
+	if len(r.sockets) == 0 || cmd.Err != nil {
+		return nil
+	}
+
+	self, err := os.Executable()
+	if err != nil {
+		return fmt.Errorf("locating goober for socket handoff: %w", err)
+	}
+
+	cmd.Args = append([]string{self, ListenExecCommand, cmd.Path}, cmd.Args...)
+	cmd.Path = self
+	cmd.ExtraFiles = r.sockets
+	cmd.Env = append(cmd.Env, "LISTEN_FDS="+strconv.Itoa(len(r.sockets)))
+	return nil
+}
+
+// ExecWithListenPID replaces the current process with the program at
+// args[0], using args[1:] as its argv, after pointing LISTEN_PID at itself.
+// It only returns on error.
+func ExecWithListenPID(args []string) error {
+	if len(args) < 2 {
+		return fmt.Errorf("usage: goober %s <path> <argv0> [args...]", ListenExecCommand)
+	}
+	env := append(os.Environ(), "LISTEN_PID="+strconv.Itoa(os.Getpid()))
+	return execProcess(args[0], args[1:], env)
+}
+
+func closeAll(files []*os.File) {
+	for _, file := range files {
+		file.Close()
+	}
+}
@@ -0,0 +1,12 @@
+//go:build !windows
+
+package internal
+
+import "syscall"
+
+const socketHandoffSupported = true
+
+// execProcess replaces the current process image, keeping its PID
+func execProcess(path string, argv, env []string) error {
+	return syscall.Exec(path, argv, env)
+}
@@ -0,0 +1,12 @@
+//go:build windows
+
+package internal
+
+import "errors"
+
+// Windows can't pass extra file descriptors to child processes
+const socketHandoffSupported = false
+
+func execProcess(path string, argv, env []string) error {
+	return errors.New("socket handoff is not supported on Windows")
+}
@@ -0,0 +1,243 @@
+package internal
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Pipeline phases, in the order they run. Pre-build, build and post-build
+// stages run while the previous instance keeps serving; pre-run stages run
+// after it has been stopped, right before the new one starts.
+const (
+	PhasePreBuild  = "pre-build"
+	PhaseBuild     = "build"
+	PhasePostBuild = "post-build"
+	PhasePreRun    = "pre-run"
+)
+
+var phaseOrder = map[string]int{
+	PhasePreBuild:  0,
+	PhaseBuild:     1,
+	PhasePostBuild: 2,
+	PhasePreRun:    3,
+}
+
+// Stage is a named step of the build pipeline
+type Stage struct {
+	Name            string            `toml:"name" yaml:"name"`
+	Phase           string            `toml:"phase" yaml:"phase"` // defaults to build
+	Cmd             string            `toml:"cmd" yaml:"cmd"`
+	Env             map[string]string `toml:"env" yaml:"env"`
+	Dir             string            `toml:"dir" yaml:"dir"`
+	Timeout         time.Duration     `toml:"timeout" yaml:"timeout"`
+	ContinueOnError bool              `toml:"continue_on_error" yaml:"continue_on_error"`
+}
+
+// StageState is where a stage is in the current pipeline run
+type StageState int
+
+const (
+	StagePending StageState = iota
+	StageRunning
+	StageSucceeded
+	StageFailed
+	StageSkipped
+	StageCancelled
+)
+
+// StageEvent reports a stage changing state
+type StageEvent struct {
+	Index   int
+	Name    string
+	Phase   string
+	State   StageState
+	Elapsed time.Duration
+	Err     error
+}
+
+type StageCallback func(event StageEvent)
+
+// Pipeline returns the stages to run, ordered by phase. Stages without a
+// phase are build stages, and the build command only runs if no stage
+// takes its place. The before_build and after_build hooks become pre-build
+// and post-build stages.
+func (c Config) Pipeline() ([]Stage, error) {
+	var stages []Stage
+	for i, cmd := range c.Hooks.BeforeBuild {
+		stages = append(stages, Stage{Name: fmt.Sprintf("before_build %d", i+1), Phase: PhasePreBuild, Cmd: cmd})
+	}
+
+	hasBuild := false
+	for _, stage := range c.Stages {
+		if stage.Phase == "" {
+			stage.Phase = PhaseBuild
+		}
+		if _, ok := phaseOrder[stage.Phase]; !ok {
+			return nil, fmt.Errorf("stage %q: unsupported phase %q (use pre-build, build, post-build or pre-run)", stage.Name, stage.Phase)
+		}
+		if strings.TrimSpace(stage.Cmd) == "" {
+			return nil, fmt.Errorf("stage %q has no cmd", stage.Name)
+		}
+		if stage.Name == "" {
+			stage.Name = strings.Fields(stage.Cmd)[0]
+		}
+		hasBuild = hasBuild || stage.Phase == PhaseBuild
+		stages = append(stages, stage)
+	}
+	if !hasBuild && c.Build != "" {
+		stages = append(stages, Stage{Name: "build", Phase: PhaseBuild, Cmd: c.Build})
+	}
+
+	for i, cmd := range c.Hooks.AfterBuild {
+		stages = append(stages, Stage{Name: fmt.Sprintf("after_build %d", i+1), Phase: PhasePostBuild, Cmd: cmd})
+	}
+
+	sort.SliceStable(stages, func(i, j int) bool {
+		return phaseOrder[stages[i].Phase] < phaseOrder[stages[j].Phase]
+	})
+	return stages, nil
+}
+
+// SetStages replaces the build pipeline
+func (r *Runner) SetStages(stages []Stage) {
+	r.stages = stages
+}
+
+// SetStageCallback sets the callback that follows stages through the pipeline
+func (r *Runner) SetStageCallback(callback StageCallback) {
+	r.stageCallback = callback
+}
+
+// stageEvent passes event to the stage callback, if any
+func (r *Runner) stageEvent(event StageEvent) {
+	if r.stageCallback != nil {
+		r.stageCallback(event)
+	}
+}
+
+// runStages runs the stages of the given phases in order. A failing stage
+// stops the pipeline unless it may continue on error; the stages after it
+// are reported as skipped.
+func (r *Runner) runStages(ctx context.Context, phases ...string) error {
+	var failed error
+	for i, stage := range r.stages {
+		if !hasPhase(phases, stage.Phase) {
+			continue
+		}
+		event := StageEvent{Index: i, Name: stage.Name, Phase: stage.Phase}
+		if failed != nil {
+			event.State = StageSkipped
+			r.stageEvent(event)
+			continue
+		}
+
+		if len(r.stages) > 1 {
+			r.log(fmt.Sprintf("[%s] %s", stage.Name, stage.Cmd), false)
+		}
+		event.State = StageRunning
+		r.stageEvent(event)
+
+		start := time.Now()
+		err := r.runStage(ctx, stage)
+		event.Elapsed = time.Since(start)
+		event.Err = err
+
+		switch {
+		case errors.Is(err, ErrBuildSuperseded):
+			event.State = StageCancelled
+			r.stageEvent(event)
+			return err
+		case err != nil && stage.ContinueOnError:
+			event.State = StageFailed
+			r.log(fmt.Sprintf("[%s] failed, continuing: %v", stage.Name, err), true)
+		case err != nil:
+			event.State = StageFailed
+			failed = err
+			if len(r.stages) > 1 {
+				failed = fmt.Errorf("stage %s: %w", stage.Name, err)
+			}
+		default:
+			event.State = StageSucceeded
+		}
+		r.stageEvent(event)
+	}
+	return failed
+}
+
+// resetStages marks every stage as pending at the start of a pipeline run
+func (r *Runner) resetStages() {
+	for i, stage := range r.stages {
+		r.stageEvent(StageEvent{Index: i, Name: stage.Name, Phase: stage.Phase, State: StagePending})
+	}
+}
+
+// runStage runs a single command with output capture, logging its output
+// tagged with the stage name. The command is killed if ctx is cancelled,
+// in which case ErrBuildSuperseded is returned.
+func (r *Runner) runStage(ctx context.Context, stage Stage) error {
+	if ctx.Err() != nil {
+		return ErrBuildSuperseded
+	}
+
+	stageCtx := ctx
+	if stage.Timeout > 0 {
+		var cancel context.CancelFunc
+		stageCtx, cancel = context.WithTimeout(ctx, stage.Timeout)
+		defer cancel()
+	}
+
+	cmd, err := r.command(stageCtx, stage.Cmd, stageEnv(stage.Env)...)
+	if err != nil {
+		return err
+	}
+	cmd.Dir = stage.Dir
+
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+
+	err = cmd.Run()
+	if ctx.Err() != nil {
+		return ErrBuildSuperseded
+	}
+
+	// Log stdout and stderr a line at a time
+	prefix := ""
+	if stage.Name != "" {
+		prefix = "[" + stage.Name + "] "
+	}
+	r.logLines(prefix, stdout.String(), false)
+	r.logLines(prefix, stderr.String(), true)
+
+	if errors.Is(stageCtx.Err(), context.DeadlineExceeded) {
+		err = fmt.Errorf("timed out after %v", stage.Timeout)
+	}
+	if err != nil {
+		return &CommandError{Output: strings.TrimSpace(stderr.String()), Err: err}
+	}
+	return nil
+}
+
+// stageEnv turns a stage's environment into sorted VAR=value pairs
+func stageEnv(env map[string]string) []string {
+	pairs := make([]string, 0, len(env))
+	for key, value := range env {
+		pairs = append(pairs, key+"="+value)
+	}
+	sort.Strings(pairs)
+	return pairs
+}
+
+func hasPhase(phases []string, phase string) bool {
+	for _, p := range phases {
+		if p == phase {
+			return true
+		}
+	}
+	return false
+}
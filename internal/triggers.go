@@ -0,0 +1,174 @@
+package internal
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// Trigger actions
+const (
+	TriggerCommand = "command" // run Cmd, then rebuild
+	TriggerRebuild = "rebuild" // rebuild and restart, like any watched file
+	TriggerRestart = "restart" // restart the app without rebuilding
+	TriggerReload  = "reload"  // only reload browsers through the proxy
+	TriggerIgnore  = "ignore"  // never react to these files
+)
+
+// actionRank orders actions so that the strongest one in a burst of
+// changes wins: a rebuild also restarts, and a restart also reloads
+var actionRank = map[string]int{
+	TriggerReload:  0,
+	TriggerRestart: 1,
+	TriggerRebuild: 2,
+	TriggerCommand: 2,
+}
+
+// Trigger maps changes to files matching Patterns to an action. Patterns
+// are globs relative to the watched directory, like include; the first
+// trigger that matches a file decides what happens to it, and files no
+// trigger matches rebuild if they are included.
+type Trigger struct {
+	Name     string   `toml:"name" yaml:"name"`
+	Patterns []string `toml:"patterns" yaml:"patterns"`
+	Action   string   `toml:"action" yaml:"action"` // defaults to command with cmd, rebuild without
+	Cmd      string   `toml:"cmd" yaml:"cmd"`
+}
+
+// ParseTriggers validates triggers and fills in their default action and name
+func ParseTriggers(triggers []Trigger) ([]Trigger, error) {
+	parsed := make([]Trigger, len(triggers))
+	for i, t := range triggers {
+		if len(t.Patterns) == 0 {
+			return nil, fmt.Errorf("trigger %d has no patterns", i+1)
+		}
+		if t.Action == "" {
+			t.Action = TriggerRebuild
+			if t.Cmd != "" {
+				t.Action = TriggerCommand
+			}
+		}
+		switch t.Action {
+		case TriggerCommand:
+			if strings.TrimSpace(t.Cmd) == "" {
+				return nil, fmt.Errorf("trigger %d: action command needs a cmd", i+1)
+			}
+		case TriggerRebuild, TriggerRestart, TriggerReload, TriggerIgnore:
+			if t.Cmd != "" {
+				return nil, fmt.Errorf("trigger %d: cmd is only used with action command", i+1)
+			}
+		default:
+			return nil, fmt.Errorf("trigger %d: unsupported action %q (use command, rebuild, restart, reload or ignore)", i+1, t.Action)
+		}
+		if t.Name == "" {
+			t.Name = t.Action
+			if t.Cmd != "" {
+				t.Name = strings.Fields(t.Cmd)[0]
+			}
+		}
+		parsed[i] = t
+	}
+	return parsed, nil
+}
+
+// fileChange is a changed file and the index of the trigger that matched
+// it, or -1 if none did
+type fileChange struct {
+	path    string
+	trigger int
+}
+
+// handleChanges acts on a debounced burst of changes. The strongest action
+// wins: trigger commands and rebuilds restart through the pipeline, with
+// the commands run first, restart-only changes restart the app from the
+// current build, and reload-only changes just refresh the browser.
+func (r *Runner) handleChanges(triggers []Trigger, changes []fileChange) {
+	action := TriggerReload
+	files := make([]string, 0, len(changes))
+	commands := map[int]bool{}
+	for _, change := range changes {
+		files = append(files, change.path)
+		changeAction := TriggerRebuild
+		if change.trigger >= 0 {
+			changeAction = triggers[change.trigger].Action
+			if changeAction == TriggerCommand {
+				commands[change.trigger] = true
+			}
+		}
+		if actionRank[changeAction] > actionRank[action] {
+			action = changeAction
+		}
+	}
+
+	switch action {
+	case TriggerReload:
+		if r.proxy == nil {
+			r.log("Changes only need a browser reload, but there is no proxy", false)
+			return
+		}
+		r.log(fmt.Sprintf("Changes detected, reloading %d browsers", r.proxy.Reload()), false)
+
+	case TriggerRestart:
+		r.log("Changes detected, restarting app without rebuilding...", false)
+		go func() {
+			if err := r.Relaunch(); err != nil {
+				r.log(fmt.Sprintf("Restart failed: %v", err), true)
+			}
+		}()
+
+	default:
+		var run []Trigger
+		for i, t := range triggers {
+			if commands[i] {
+				run = append(run, t)
+			}
+		}
+		r.log("Changes detected, restarting...", false)
+
+		// Restart in the background so that the next change can supersede
+		// a slow build instead of queueing behind it
+		go func() {
+			if err := r.restart(files, run); err != nil {
+				if !errors.Is(err, ErrBuildSuperseded) {
+					r.log(fmt.Sprintf("Restart failed: %v", err), true)
+				}
+			} else {
+				r.log("Restart successful", false)
+			}
+		}()
+	}
+}
+
+// runTriggers runs the commands of the triggers that fired, in order,
+// stopping at the first failure
+func (r *Runner) runTriggers(ctx context.Context, triggers []Trigger) error {
+	for _, t := range triggers {
+		r.log(fmt.Sprintf("[%s] %s", t.Name, t.Cmd), false)
+		if err := r.runStage(ctx, Stage{Name: t.Name, Cmd: t.Cmd}); err != nil {
+			if errors.Is(err, ErrBuildSuperseded) {
+				return err
+			}
+			return fmt.Errorf("trigger %s: %w", t.Name, err)
+		}
+	}
+	return nil
+}
+
+// triggerPatterns holds a trigger's normalized patterns
+type triggerPatterns struct {
+	action   string
+	patterns []string
+}
+
+// compileTriggers normalizes the patterns of triggers for the matcher
+func compileTriggers(triggers []Trigger) []triggerPatterns {
+	compiled := make([]triggerPatterns, len(triggers))
+	for i, t := range triggers {
+		compiled[i].action = t.Action
+		for _, pattern := range t.Patterns {
+			compiled[i].patterns = append(compiled[i].patterns, normalizePattern(pattern))
+		}
+	}
+	return compiled
+}
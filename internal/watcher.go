@@ -11,40 +11,126 @@ import (
 	"github.com/fsnotify/fsnotify"
 )
 
-func (r *Runner) WatchAndRun(dir string) {
+func (r *Runner) WatchAndRun(cfg WatchConfig) {
+	if r.proxy != nil {
+		go r.serveProxy()
+	}
+
 	if err := r.Start(); err != nil {
 		r.log(fmt.Sprintf("Initial start failed: %v", err), true)
 	}
 
-	watcher, err := fsnotify.NewWatcher()
+	r.watch(cfg, func(changes []fileChange) {
+		r.handleChanges(cfg.Triggers, changes)
+	})
+}
+
+// WatchAndTest is the test-only counterpart of WatchAndRun: there is no
+// app, and every change reruns go test on the affected packages
+func (r *Runner) WatchAndTest(cfg WatchConfig) {
+	go r.RunTests(nil, r.TestFilter())
+
+	r.watch(cfg, func(changes []fileChange) {
+		files := make([]string, len(changes))
+		for i, change := range changes {
+			files[i] = change.path
+		}
+		r.log("Changes detected, testing...", false)
+		go r.runTests(r.supersedeBuild(), files)
+	})
+}
+
+// watch calls onChange with the files that changed in each debounced
+// burst of file system events, and the triggers they matched, until the
+// watcher fails
+func (r *Runner) watch(cfg WatchConfig, onChange func(changes []fileChange)) {
+	matcher := newPathMatcher(cfg)
+	hashes := newContentHashes()
+	watched, err := r.watchRoots(cfg, matcher, hashes)
 	if err != nil {
 		r.log(fmt.Sprintf("Failed to create watcher: %v", err), true)
 		return
 	}
-	defer watcher.Close()
-
-	// Add directories recursively
-	addDirs(watcher, dir)
-	r.log(fmt.Sprintf("Watching directory: %s", dir), false)
+	watcher := watched.backend
+	defer watched.close()
 
 	debounce := time.NewTimer(time.Hour)
 	debounce.Stop()
 	mu := sync.Mutex{}
+	burst := newChangeBurst()
+
+	// Report saves that changed nothing once things have been quiet for a
+	// debounce period, unless a real change is already pending
+	skipReport := time.AfterFunc(time.Hour, func() {
+		mu.Lock()
+		defer mu.Unlock()
+		if len(burst.changed) == 0 && len(burst.saved) > 0 {
+			r.log(fmt.Sprintf("%d files saved, 0 changed — skipped", len(burst.saved)), false)
+			burst = newChangeBurst()
+		}
+	})
+	skipReport.Stop()
 
 	go func() {
 		for {
 			select {
-			case event, ok := <-watcher.Events:
+			case event, ok := <-watcher.Events():
 				if !ok {
 					return
 				}
-				if strings.HasSuffix(event.Name, ".go") {
+				event.Name = filepath.Clean(event.Name)
+				if isIgnoreFile(event.Name) {
+					matcher.loadIgnores(filepath.Dir(event.Name))
+				}
+
+				trigger, matched := matcher.classify(event.Name)
+				changed := false
+				if event.Has(fsnotify.Create) {
+					if info, err := os.Stat(event.Name); err == nil && info.IsDir() {
+						// Files may land in the new directory before it is watched
+						n, found, err := watched.walk(event.Name)
+						if isWatchLimitErr(err) {
+							r.log(fmt.Sprintf("inotify limit reached (%v), falling back to polling", err), true)
+							poller := newPollBackend(cfg.PollInterval, cfg.PollMode)
+							if found, err = watched.switchBackend(poller, cfg.Roots); err != nil {
+								r.log(fmt.Sprintf("Failed to switch to polling: %v", err), true)
+							} else {
+								for _, root := range cfg.Roots {
+									r.log(fmt.Sprintf("Polling directory: %s (every %s)", root, poller.interval), false)
+								}
+							}
+							watcher = watched.backend
+							changed = found
+						} else if n > 0 {
+							r.log(fmt.Sprintf("Watching new directory: %s", event.Name), false)
+							changed = found
+						}
+					}
+				}
+				if event.Has(fsnotify.Remove) || event.Has(fsnotify.Rename) {
+					if n := watched.removeTree(event.Name); n > 0 {
+						r.log(fmt.Sprintf("Stopped watching directory: %s", event.Name), false)
+					}
+				}
+
+				if matched && hashes.changed(event.Name) {
+					changed = true
+				}
+
+				mu.Lock()
+				if matched {
+					burst.saved[event.Name] = true
+					burst.triggers[event.Name] = trigger
+				}
+				if changed {
+					burst.changed[event.Name] = true
 					r.log(fmt.Sprintf("Change detected: %s", event.Name), false)
-					mu.Lock()
-					debounce.Reset(750 * time.Millisecond)
-					mu.Unlock()
+					debounce.Reset(cfg.Debounce)
+				} else if matched {
+					skipReport.Reset(cfg.Debounce)
 				}
-			case err, ok := <-watcher.Errors:
+				mu.Unlock()
+			case err, ok := <-watcher.Errors():
 				if ok {
 					r.log(fmt.Sprintf("Watcher error: %v", err), true)
 				}
@@ -54,30 +140,191 @@ func (r *Runner) WatchAndRun(dir string) {
 
 	for {
 		<-debounce.C
-		r.log("Changes detected, restarting...", false)
-		r.Stop()
-		
-		// Notify about restart
-		if r.restartCallback != nil {
-			r.restartCallback()
-		}
-		
-		if err := r.Start(); err != nil {
-			r.log(fmt.Sprintf("Restart failed: %v", err), true)
-		} else {
-			r.log("Restart successful", false)
+		mu.Lock()
+		var changes []fileChange
+		for path := range burst.saved {
+			if hashes.commit(path) {
+				changes = append(changes, fileChange{path: path, trigger: burst.triggers[path]})
+			}
+		}
+		for path := range burst.changed {
+			if !burst.saved[path] {
+				// New directories, already committed while walking them
+				changes = append(changes, fileChange{path: path, trigger: -1})
+			}
+		}
+		changed := len(changes)
+		saved := len(burst.saved)
+		burst = newChangeBurst()
+		mu.Unlock()
+
+		if changed == 0 {
+			r.log(fmt.Sprintf("%d files saved, 0 changed — skipped", saved), false)
+			continue
 		}
+		if saved > changed {
+			r.log(fmt.Sprintf("%d files saved, %d changed", saved, changed), false)
+		}
+		onChange(changes)
 	}
 }
 
-func addDirs(watcher *fsnotify.Watcher, root string) {
-	filepath.Walk(root, func(path string, info os.FileInfo, err error) error {
-		if err != nil {
+// serveProxy runs the live-reload proxy for as long as it can
+func (r *Runner) serveProxy() {
+	r.log(fmt.Sprintf("Proxy listening on %s, forwarding to %s", r.proxy.listen, r.proxy.Target()), false)
+	if err := r.proxy.ListenAndServe(); err != nil {
+		r.log(fmt.Sprintf("Proxy error: %v", err), true)
+	}
+}
+
+// watchRoots creates the watch backend and adds every root to it. Unless
+// polling was requested it uses fsnotify, falling back to polling when the
+// inotify watch limit is hit.
+func (r *Runner) watchRoots(cfg WatchConfig, matcher *pathMatcher, hashes *contentHashes) (*watchSet, error) {
+	if !cfg.Poll {
+		backend, err := newFsnotifyBackend()
+		if err == nil {
+			watched := newWatchSet(backend, matcher, hashes)
+			if err = watched.addRoots(cfg.Roots); err == nil {
+				for _, root := range cfg.Roots {
+					r.log(fmt.Sprintf("Watching directory: %s", root), false)
+				}
+				return watched, nil
+			}
+			backend.Close()
+		}
+		if !isWatchLimitErr(err) {
+			return nil, err
+		}
+		r.log(fmt.Sprintf("inotify limit reached (%v), falling back to polling", err), true)
+	}
+
+	poller := newPollBackend(cfg.PollInterval, cfg.PollMode)
+	watched := newWatchSet(poller, matcher, hashes)
+	if err := watched.addRoots(cfg.Roots); err != nil {
+		poller.Close()
+		return nil, err
+	}
+	for _, root := range cfg.Roots {
+		r.log(fmt.Sprintf("Polling directory: %s (every %s)", root, poller.interval), false)
+	}
+	return watched, nil
+}
+
+// watchSet tracks the directories registered with the watch backend
+type watchSet struct {
+	backend WatchBackend
+	matcher *pathMatcher
+	hashes  *contentHashes
+	mu      sync.Mutex
+	dirs    map[string]bool
+}
+
+func newWatchSet(backend WatchBackend, matcher *pathMatcher, hashes *contentHashes) *watchSet {
+	return &watchSet{backend: backend, matcher: matcher, hashes: hashes, dirs: map[string]bool{}}
+}
+
+// addRoots watches every root tree, stopping at the first error
+func (w *watchSet) addRoots(roots []string) error {
+	for _, root := range roots {
+		if _, _, err := w.walk(root); err != nil {
 			return err
 		}
-		if info.IsDir() && !strings.Contains(path, ".git") && !strings.Contains(path, "vendor") {
-			watcher.Add(path)
+	}
+	return nil
+}
+
+// walk watches root and every directory below it that isn't ignored. It
+// returns how many directories were added and whether a file that should
+// trigger a rebuild was found along the way. Errors adding a watch are only
+// returned when they come from hitting the watch limit, which stops the walk.
+func (w *watchSet) walk(root string) (int, bool, error) {
+	root = filepath.Clean(root)
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	added, found := 0, false
+	err := filepath.Walk(root, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			if path == root {
+				return err
+			}
+			return nil
+		}
+		if !info.IsDir() {
+			if w.matcher.matchesFile(path) && w.hashes.commit(path) {
+				found = true
+			}
+			return nil
+		}
+		if w.matcher.skipsDir(path) {
+			return filepath.SkipDir
+		}
+		w.matcher.loadIgnores(path)
+		if w.dirs[path] {
+			return nil
 		}
+		if err := w.backend.Add(path); err != nil {
+			if isWatchLimitErr(err) {
+				return err
+			}
+			return nil
+		}
+		w.dirs[path] = true
+		added++
 		return nil
 	})
+	return added, found, err
+}
+
+// switchBackend moves the watch set over to backend, closing the old one,
+// and watches the roots again from scratch. It reports whether a file that
+// should trigger a rebuild was found along the way.
+func (w *watchSet) switchBackend(backend WatchBackend, roots []string) (bool, error) {
+	w.mu.Lock()
+	old := w.backend
+	w.backend = backend
+	w.dirs = map[string]bool{}
+	w.mu.Unlock()
+	old.Close()
+
+	found := false
+	for _, root := range roots {
+		_, f, err := w.walk(root)
+		if err != nil {
+			return found, err
+		}
+		found = found || f
+	}
+	return found, nil
+}
+
+// close closes the current backend
+func (w *watchSet) close() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.backend.Close()
+}
+
+// removeTree stops watching dir and every directory below it
+func (w *watchSet) removeTree(dir string) int {
+	dir = filepath.Clean(dir)
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	removed := 0
+	prefix := dir + string(filepath.Separator)
+	for path := range w.dirs {
+		if path == dir || strings.HasPrefix(path, prefix) {
+			// The kernel drops watches on deleted directories by itself, so
+			// an error here only means there was nothing left to remove
+			w.backend.Remove(path)
+			delete(w.dirs, path)
+			removed++
+		}
+	}
+	if removed > 0 {
+		w.matcher.forgetIgnores(dir)
+	}
+	return removed
 }
@@ -1,10 +1,14 @@
 package main
 
 import (
+	"bufio"
 	"flag"
 	"fmt"
 	"os"
 	"os/signal"
+	"path/filepath"
+	"strconv"
+	"strings"
 	"syscall"
 	"time"
 
@@ -13,19 +17,217 @@ import (
 )
 
 func main() {
-    dir := flag.String("dir", ".", "Directory to watch")
-    buildCmd := flag.String("build", "go build -o app", "Build command")
-    runCmd := flag.String("run", "./app", "Run command")
-    debounce := flag.Duration("debounce", 750*time.Millisecond, "Debounce duration for file changes")
+    if len(os.Args) > 1 && os.Args[1] == internal.ListenExecCommand {
+        err := internal.ExecWithListenPID(os.Args[2:])
+        fmt.Fprintf(os.Stderr, "goober: %v\n", err)
+        os.Exit(127)
+    }
+
+    if len(os.Args) > 1 && os.Args[1] == "test" {
+        if err := runTest(os.Args[2:]); err != nil {
+            fmt.Fprintf(os.Stderr, "goober test: %v\n", err)
+            os.Exit(1)
+        }
+        return
+    }
+
+    if len(os.Args) > 1 && os.Args[1] == "init" {
+        if err := runInit(os.Args[2:]); err != nil {
+            fmt.Fprintf(os.Stderr, "goober init: %v\n", err)
+            os.Exit(1)
+        }
+        return
+    }
+
+    defaults := internal.DefaultConfig()
+
+    dir := flag.String("dir", defaults.Dir, "Directory to watch")
+    buildCmd := flag.String("build", defaults.Build, "Build command")
+    runCmd := flag.String("run", defaults.Run, "Run command")
+    shell := flag.Bool("shell", false, "Run build and run commands through sh -c (for pipelines and && chains)")
+    debounce := flag.Duration("debounce", defaults.Debounce, "Debounce duration for file changes")
+    var include, exclude stringList
+    flag.Var(&include, "include", "Glob of files to watch, e.g. '**/*.tmpl' (repeatable, '!' negates)")
+    flag.Var(&exclude, "exclude", "Glob of paths to ignore, e.g. 'node_modules/**' (repeatable)")
+    stopSignal := flag.String("stop-signal", defaults.StopSignal, "Signal sent to the app on stop (SIGTERM, SIGINT or SIGQUIT)")
+    killTimeout := flag.Duration("kill-timeout", defaults.KillTimeout, "How long to wait after the stop signal before killing the app")
+    var ports intList
+    flag.Var(&ports, "port", "Port the app listens on; goober waits for it to be free before starting (repeatable)")
+    portTimeout := flag.Duration("port-timeout", defaults.PortTimeout, "How long to wait for --port to be released")
+    readyHTTP := flag.String("ready-http", "", "URL that must answer GET with a 2xx before the app counts as ready")
+    readyTCP := flag.String("ready-tcp", "", "Address that must accept TCP connections before the app counts as ready")
+    readyLog := flag.String("ready-log", "", "Regexp an output line must match before the app counts as ready")
+    readyTimeout := flag.Duration("ready-timeout", 30*time.Second, "How long to wait for the app to become ready")
+    restart := flag.String("restart", defaults.Restart.Policy, "Restart policy when the app exits on its own: never, on-failure or always")
+    proxyListen := flag.String("proxy", "", "Address for a live-reload proxy in front of the app, e.g. :3000")
+    proxyTarget := flag.String("target", "", "Address the app listens on, used with --proxy, e.g. :8080")
+    var sockets stringList
+    flag.Var(&sockets, "socket", "Listening socket goober owns and passes to the app via LISTEN_FDS, e.g. :8080 or unix:/tmp/app.sock (repeatable)")
+    poll := flag.Bool("poll", false, "Poll for changes instead of using inotify (for Docker, NFS and VM mounts)")
+    pollInterval := flag.Duration("poll-interval", 500*time.Millisecond, "Polling interval when --poll is used")
+    test := flag.Bool("test", false, "Run go test on the packages affected by each change once the new build is running")
+    editor := flag.String("editor", "", "Command template for opening errors from the TUI, e.g. \"code --goto {file}:{line}:{col}\" (default: $EDITOR)")
+    configPath := flag.String("config", "", "Config file (default: goober.toml or .goober.yaml in --dir)")
     noTUI := flag.Bool("no-tui", false, "Disable TUI and use simple CLI output")
     flag.Parse()
 
-    runner := internal.NewRunner(*buildCmd, *runCmd)
+    cfg := defaults
+
+    // Load the project config file, if there is one
+    path := *configPath
+    if path == "" {
+        path = internal.FindConfig(*dir)
+    }
+    if path != "" {
+        if err := internal.LoadConfig(path, &cfg); err != nil {
+            fmt.Fprintf(os.Stderr, "Error loading config: %v\n", err)
+            os.Exit(1)
+        }
+    }
+
+    // Flags given on the command line override the config file
+    flag.Visit(func(f *flag.Flag) {
+        switch f.Name {
+        case "dir":
+            cfg.Dir = *dir
+        case "build":
+            // --build replaces any build stages from the config file
+            cfg.Build = *buildCmd
+            stages := cfg.Stages[:0]
+            for _, stage := range cfg.Stages {
+                if stage.Phase != "" && stage.Phase != internal.PhaseBuild {
+                    stages = append(stages, stage)
+                }
+            }
+            cfg.Stages = stages
+        case "run":
+            cfg.Run = *runCmd
+        case "shell":
+            cfg.Shell = *shell
+        case "debounce":
+            cfg.Debounce = *debounce
+        case "include":
+            cfg.Include = include
+        case "exclude":
+            cfg.Ignore = append(cfg.Ignore, exclude...)
+        case "stop-signal":
+            cfg.StopSignal = *stopSignal
+        case "kill-timeout":
+            cfg.KillTimeout = *killTimeout
+        case "port":
+            cfg.Ports = ports
+        case "port-timeout":
+            cfg.PortTimeout = *portTimeout
+        case "ready-http":
+            cfg.Ready.HTTP = *readyHTTP
+        case "ready-tcp":
+            cfg.Ready.TCP = *readyTCP
+        case "ready-log":
+            cfg.Ready.Log = *readyLog
+        case "ready-timeout":
+            cfg.Ready.Timeout = *readyTimeout
+        case "restart":
+            cfg.Restart.Policy = *restart
+        case "proxy":
+            cfg.Proxy.Listen = *proxyListen
+        case "target":
+            cfg.Proxy.Target = *proxyTarget
+        case "socket":
+            cfg.Sockets = sockets
+        case "poll":
+            cfg.Poll = *poll
+        case "poll-interval":
+            cfg.PollInterval = *pollInterval
+        case "test":
+            cfg.Test.Enabled = *test
+        case "editor":
+            cfg.Editor = *editor
+        }
+    })
+
+    stages, err := cfg.Pipeline()
+    if err != nil {
+        fmt.Fprintf(os.Stderr, "Invalid config: %v\n", err)
+        os.Exit(1)
+    }
+    if cfg.Triggers, err = internal.ParseTriggers(cfg.Triggers); err != nil {
+        fmt.Fprintf(os.Stderr, "Invalid config: %v\n", err)
+        os.Exit(1)
+    }
+    if !cfg.Shell {
+        cmds := []string{cfg.Run}
+        for _, stage := range stages {
+            cmds = append(cmds, stage.Cmd)
+        }
+        for _, trigger := range cfg.Triggers {
+            if trigger.Cmd != "" {
+                cmds = append(cmds, trigger.Cmd)
+            }
+        }
+        for _, cmd := range cmds {
+            if _, err := internal.ParseCommand(cmd); err != nil {
+                fmt.Fprintf(os.Stderr, "Invalid command: %v\n", err)
+                os.Exit(1)
+            }
+        }
+    }
+
+    sig, err := internal.ParseSignal(cfg.StopSignal)
+    if err != nil {
+        fmt.Fprintf(os.Stderr, "Invalid config: %v\n", err)
+        os.Exit(1)
+    }
+    if cfg.Restart.Policy, err = internal.ParseRestartPolicy(cfg.Restart.Policy); err != nil {
+        fmt.Fprintf(os.Stderr, "Invalid config: %v\n", err)
+        os.Exit(1)
+    }
+
+    var proxy *internal.Proxy
+    if cfg.Proxy.Listen != "" {
+        if cfg.Proxy.Target == "" {
+            fmt.Fprintln(os.Stderr, "Invalid config: --proxy needs --target")
+            os.Exit(1)
+        }
+        if proxy, err = internal.NewProxy(cfg.Proxy.Listen, cfg.Proxy.Target); err != nil {
+            fmt.Fprintf(os.Stderr, "Invalid config: %v\n", err)
+            os.Exit(1)
+        }
+        proxy.SetHold(cfg.Proxy.HoldTimeout, cfg.Proxy.QueueSize)
+
+        // Don't reload browsers before the app accepts connections
+        if !cfg.Ready.HasProbes() {
+            cfg.Ready.TCP = proxy.Target().Host
+        }
+    }
+
+    runner := internal.NewRunner(cfg.Build, cfg.Run)
+    runner.SetShell(cfg.Shell)
+    runner.SetStopSignal(sig)
+    runner.SetKillTimeout(cfg.KillTimeout)
+    runner.SetPorts(cfg.Ports, cfg.PortTimeout)
+    runner.SetReadyCheck(cfg.Ready)
+    runner.SetRestartPolicy(cfg.Restart)
+    if proxy != nil {
+        runner.SetProxy(proxy)
+    }
+    if err := runner.SetSockets(cfg.Sockets); err != nil {
+        fmt.Fprintf(os.Stderr, "Error opening sockets: %v\n", err)
+        os.Exit(1)
+    }
+    runner.SetEnv(cfg.Env)
+    runner.SetHooks(cfg.Hooks)
+    runner.SetStages(stages)
+    runner.SetTests(cfg.Test)
+
+    watchConfig := cfg.WatchConfig()
 
     if *noTUI {
         // Original CLI mode
         fmt.Println("Starting Goober...")
-        go runner.WatchAndRun(*dir)
+        if path != "" {
+            fmt.Printf("Using config %s\n", path)
+        }
+        go runner.WatchAndRun(watchConfig)
 
         // Wait for Ctrl+C
         sig := make(chan os.Signal, 1)
@@ -35,10 +237,11 @@ func main() {
         fmt.Println("Goober stopped.")
     } else {
         // TUI mode
-        tuiApp := tui.NewTUI(*dir, *debounce, runner)
+        tuiApp := tui.NewTUI(watchConfig, runner)
+        tuiApp.SetEditor(cfg.Editor)
         
         // Start the watcher in a goroutine
-        go runner.WatchAndRun(*dir)
+        go runner.WatchAndRun(watchConfig)
         
         // Start the TUI (this blocks until quit)
         if err := tuiApp.Start(); err != nil {
@@ -50,3 +253,175 @@ func main() {
         tuiApp.Stop()
     }
 }
+
+// stringList is a flag that can be given several times
+type stringList []string
+
+func (l *stringList) String() string {
+    return strings.Join(*l, ",")
+}
+
+func (l *stringList) Set(value string) error {
+    *l = append(*l, value)
+    return nil
+}
+
+// intList is an integer flag that can be given several times
+type intList []int
+
+func (l *intList) String() string {
+    parts := make([]string, len(*l))
+    for i, v := range *l {
+        parts[i] = strconv.Itoa(v)
+    }
+    return strings.Join(parts, ",")
+}
+
+func (l *intList) Set(value string) error {
+    v, err := strconv.Atoi(value)
+    if err != nil {
+        return fmt.Errorf("invalid port %q", value)
+    }
+    *l = append(*l, v)
+    return nil
+}
+
+// runTest watches the project and reruns go test on every change, without
+// building or running an app. Arguments after -- are passed to go test.
+func runTest(args []string) error {
+    defaults := internal.DefaultConfig()
+    fs := flag.NewFlagSet("test", flag.ExitOnError)
+    dir := fs.String("dir", defaults.Dir, "Directory to watch")
+    debounce := fs.Duration("debounce", defaults.Debounce, "Debounce duration for file changes")
+    var include, exclude stringList
+    fs.Var(&include, "include", "Glob of files to watch (repeatable, '!' negates)")
+    fs.Var(&exclude, "exclude", "Glob of paths to ignore (repeatable)")
+    filter := fs.String("filter", "", "Only run tests matching this regexp (go test -run)")
+    poll := fs.Bool("poll", false, "Poll for changes instead of using inotify")
+    configPath := fs.String("config", "", "Config file (default: goober.toml or .goober.yaml in --dir)")
+    noTUI := fs.Bool("no-tui", false, "Disable TUI and use simple CLI output")
+    fs.Parse(args)
+
+    cfg := defaults
+    path := *configPath
+    if path == "" {
+        path = internal.FindConfig(*dir)
+    }
+    if path != "" {
+        if err := internal.LoadConfig(path, &cfg); err != nil {
+            return fmt.Errorf("loading config: %w", err)
+        }
+    }
+    fs.Visit(func(f *flag.Flag) {
+        switch f.Name {
+        case "dir":
+            cfg.Dir = *dir
+        case "debounce":
+            cfg.Debounce = *debounce
+        case "include":
+            cfg.Include = include
+        case "exclude":
+            cfg.Ignore = append(cfg.Ignore, exclude...)
+        case "poll":
+            cfg.Poll = *poll
+        }
+    })
+    cfg.Test.Args = append(cfg.Test.Args, fs.Args()...)
+    var err error
+    if cfg.Triggers, err = internal.ParseTriggers(cfg.Triggers); err != nil {
+        return fmt.Errorf("invalid config: %w", err)
+    }
+
+    runner := internal.NewRunner("", "")
+    runner.SetEnv(cfg.Env)
+    runner.SetTests(cfg.Test)
+    runner.SetTestFilter(*filter)
+    watchConfig := cfg.WatchConfig()
+
+    if *noTUI {
+        go runner.WatchAndTest(watchConfig)
+
+        sig := make(chan os.Signal, 1)
+        signal.Notify(sig, syscall.SIGINT, syscall.SIGTERM)
+        <-sig
+        runner.Stop()
+        return nil
+    }
+
+    tuiApp := tui.NewTestTUI(watchConfig, runner)
+    go runner.WatchAndTest(watchConfig)
+    if err := tuiApp.Start(); err != nil {
+        return err
+    }
+    tuiApp.Stop()
+    return nil
+}
+
+// runInit scaffolds a goober.toml from the detected project layout
+func runInit(args []string) error {
+    fs := flag.NewFlagSet("init", flag.ExitOnError)
+    dir := fs.String("dir", ".", "Project directory")
+    mainName := fs.String("main", "", "Main package to build when there are several")
+    force := fs.Bool("force", false, "Overwrite an existing config file")
+    fs.Parse(args)
+
+    if existing := internal.FindConfig(*dir); existing != "" && !*force {
+        return fmt.Errorf("%s already exists (use --force to overwrite)", existing)
+    }
+
+    layout, err := internal.DetectProject(*dir)
+    if err != nil {
+        return err
+    }
+    if len(layout.Mains) == 0 {
+        return fmt.Errorf("no main package found in %s or %s", *dir, filepath.Join(*dir, "cmd"))
+    }
+
+    pkg := layout.Mains[0]
+    if *mainName != "" {
+        found := false
+        for _, m := range layout.Mains {
+            if m.Name == *mainName || m.Path == *mainName {
+                pkg, found = m, true
+                break
+            }
+        }
+        if !found {
+            return fmt.Errorf("main package %q not found", *mainName)
+        }
+    } else if len(layout.Mains) > 1 {
+        pkg, err = promptMain(layout.Mains)
+        if err != nil {
+            return err
+        }
+    }
+
+    path := filepath.Join(*dir, "goober.toml")
+    if err := os.WriteFile(path, []byte(internal.ScaffoldConfig(layout, pkg)), 0644); err != nil {
+        return err
+    }
+    fmt.Printf("Wrote %s (build: %s)\n", path, pkg.Path)
+    return nil
+}
+
+// promptMain asks which of several main packages goober should build
+func promptMain(mains []internal.MainPackage) (internal.MainPackage, error) {
+    fmt.Println("Found several main packages:")
+    for i, m := range mains {
+        fmt.Printf("  %d) %s\n", i+1, m.Path)
+    }
+
+    reader := bufio.NewReader(os.Stdin)
+    for {
+        fmt.Printf("Which one should goober build? [1-%d]: ", len(mains))
+        line, err := reader.ReadString('\n')
+        choice, convErr := strconv.Atoi(strings.TrimSpace(line))
+        if convErr == nil && choice >= 1 && choice <= len(mains) {
+            return mains[choice-1], nil
+        }
+        if err != nil {
+            return internal.MainPackage{}, fmt.Errorf("no main package selected")
+        }
+        fmt.Println("Please enter a number from the list.")
+    }
+}
@@ -0,0 +1,103 @@
+package tui
+
+import (
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/jerkeyray/goober/internal"
+)
+
+// editorFinishedMsg is sent when the editor exits or fails to start
+type editorFinishedMsg struct {
+	err error
+}
+
+// SetEditor sets the command template used to open source locations.
+// An empty template falls back to $VISUAL or $EDITOR.
+func (t *TUI) SetEditor(template string) {
+	t.model.editor = template
+}
+
+// locationUp moves the log cursor to the previous entry with a source
+// location, starting from the bottom of the view if there is no cursor yet
+func (m *Model) locationUp() {
+	from := m.logCursor
+	if from < 0 {
+		from = m.logViewStart + len(m.getVisibleLogs())
+	}
+	for i := from - 1; i >= 0; i-- {
+		if m.logs[i].Location != nil {
+			m.logCursor = i
+			m.showLog(i)
+			return
+		}
+	}
+}
+
+// locationDown moves the log cursor to the next entry with a source
+// location, starting from the top of the view if there is no cursor yet
+func (m *Model) locationDown() {
+	from := m.logCursor
+	if from < 0 {
+		from = m.logViewStart - 1
+	}
+	for i := from + 1; i < len(m.logs); i++ {
+		if m.logs[i].Location != nil {
+			m.logCursor = i
+			m.showLog(i)
+			return
+		}
+	}
+}
+
+// showLog scrolls the log view just enough to bring entry i into it
+func (m *Model) showLog(i int) {
+	maxVisible := m.getMaxVisibleLogs()
+	if i < m.logViewStart {
+		m.logViewStart = i
+	} else if maxVisible > 0 && i >= m.logViewStart+maxVisible {
+		m.logViewStart = i - maxVisible + 1
+	}
+}
+
+// selectedLocation returns the source location o/Enter opens: the selected
+// problem, or the file:line under the log cursor
+func (m Model) selectedLocation() (internal.Diagnostic, bool) {
+	if m.focus == PanelProblems && len(m.problems) > 0 {
+		return m.problems[m.problemCursor].Diagnostic, true
+	}
+	if m.focus != PanelLogs {
+		return internal.Diagnostic{}, false
+	}
+	if m.logCursor >= 0 && m.logCursor < len(m.logs) {
+		return *m.logs[m.logCursor].Location, true
+	}
+	return internal.Diagnostic{}, false
+}
+
+// openEditor opens the selected location in the editor. Terminal editors
+// take over the screen until they exit; GUI editors are started in the
+// background.
+func (m Model) openEditor() tea.Cmd {
+	loc, ok := m.selectedLocation()
+	if !ok {
+		return nil
+	}
+
+	cmd, terminal, err := internal.EditorCommand(m.editor, loc.File, loc.Line, loc.Column)
+	if err != nil {
+		return func() tea.Msg {
+			return editorFinishedMsg{err: err}
+		}
+	}
+	if !terminal {
+		return func() tea.Msg {
+			if err := cmd.Start(); err != nil {
+				return editorFinishedMsg{err: err}
+			}
+			go cmd.Wait()
+			return editorFinishedMsg{}
+		}
+	}
+	return tea.ExecProcess(cmd, func(err error) tea.Msg {
+		return editorFinishedMsg{err: err}
+	})
+}
@@ -2,9 +2,11 @@ package tui
 
 import (
 	"fmt"
+	"strings"
 	"time"
 
 	"github.com/charmbracelet/lipgloss"
+	"github.com/jerkeyray/goober/internal"
 )
 
 // BuildStatus represents the status of the last build
@@ -13,8 +15,12 @@ type BuildStatus int
 const (
 	StatusWatching BuildStatus = iota
 	StatusBuilding
+	StatusStarting
 	StatusSuccess
 	StatusError
+	StatusErrorServing // build failed, previous build still running
+	StatusNotReady
+	StatusExited
 )
 
 // LogType determines styling for log entries
@@ -26,6 +32,7 @@ const (
 	LogTypeError
 	LogTypeRestart
 	LogTypeEvent
+	LogTypeExit
 )
 
 // LogEntry represents a single log entry with timestamp and styling
@@ -33,19 +40,36 @@ type LogEntry struct {
 	Message   string
 	Type      LogType
 	Timestamp time.Time
+	Location  *internal.Diagnostic // file:line the message points at, if any
 }
 
 // Model represents the state of our TUI application
 type Model struct {
 	// Status bar data
-	watchDir         string
-	debounceDuration time.Duration
-	restartCount     int
-	status           BuildStatus
+	watchConfig  internal.WatchConfig
+	restartCount int
+	status       BuildStatus
 
 	// Logs
 	logs         []LogEntry
 	logViewStart int // For scrolling
+	logCursor    int // log entry with a source location that o/Enter opens, or -1
+
+	// Problems from the last build
+	problems      []Problem
+	problemCursor int
+	focus         Panel
+
+	// Pipeline stages of the current build
+	stages []internal.StageEvent
+
+	// Results of the last test run
+	testReport   *internal.TestReport
+	testsRunning bool
+	testCursor   int
+
+	// Command template for opening source locations
+	editor string
 
 	// UI state
 	width  int
@@ -81,25 +105,29 @@ type Styles struct {
 	LogEntryError   lipgloss.Style
 	LogEntryRestart lipgloss.Style
 	LogEntryEvent   lipgloss.Style
+	LogEntryExit    lipgloss.Style
+	ProblemsPanel   lipgloss.Style
+	ProblemsTitle   lipgloss.Style
+	ProblemSelected lipgloss.Style
 	HelpBar         lipgloss.Style
 	HelpKey         lipgloss.Style
 	HelpDesc        lipgloss.Style
 }
 
 // NewModel creates a new model with default values
-func NewModel(watchDir string, debounceDuration time.Duration) Model {
+func NewModel(watchConfig internal.WatchConfig) Model {
 	return Model{
-		watchDir:         watchDir,
-		debounceDuration: debounceDuration,
-		restartCount:     0,
-		status:           StatusWatching,
-		logs:             []LogEntry{},
-		logViewStart:     0,
-		width:            80,
-		height:           24,
-		ready:            false,
-		styles:           makeStyles(),
-		logChan:          make(chan LogMessage, 100),
+		watchConfig:  watchConfig,
+		restartCount: 0,
+		status:       StatusWatching,
+		logs:         []LogEntry{},
+		logViewStart: 0,
+		logCursor:    -1,
+		width:        80,
+		height:       24,
+		ready:        false,
+		styles:       makeStyles(),
+		logChan:      make(chan LogMessage, 100),
 	}
 }
 
@@ -139,6 +167,14 @@ func makeStyles() Styles {
 		LogEntryError:   lipgloss.NewStyle().Foreground(red),
 		LogEntryRestart: lipgloss.NewStyle().Foreground(orange),
 		LogEntryEvent:   lipgloss.NewStyle().Foreground(blue),
+		LogEntryExit:    lipgloss.NewStyle().Foreground(yellow),
+
+		ProblemsPanel: lipgloss.NewStyle().
+			Border(lipgloss.RoundedBorder()).
+			BorderForeground(comment).
+			Padding(0, 1),
+		ProblemsTitle:   lipgloss.NewStyle().Foreground(red).Bold(true),
+		ProblemSelected: lipgloss.NewStyle().Background(lipgloss.Color("#292e42")),
 
 		HelpBar:  lipgloss.NewStyle().Background(bg).Foreground(darkFg).Padding(0, 1),
 		HelpKey:  lipgloss.NewStyle().Foreground(blue).Bold(true),
@@ -153,6 +189,9 @@ func (m *Model) AddLog(message string, logType LogType) {
 		Type:      logType,
 		Timestamp: time.Now(),
 	}
+	if loc, ok := internal.ParseLocation(message); ok {
+		entry.Location = &loc
+	}
 	m.logs = append(m.logs, entry)
 
 	// Auto-scroll to bottom when new logs are added
@@ -160,12 +199,19 @@ func (m *Model) AddLog(message string, logType LogType) {
 	if len(m.logs) > maxVisible {
 		m.logViewStart = len(m.logs) - maxVisible
 	}
+
+	// A new location takes the cursor once the old one has scrolled away,
+	// so the first error of a failed build is selected
+	if entry.Location != nil && (m.logCursor < 0 || m.logCursor < m.logViewStart) {
+		m.logCursor = len(m.logs) - 1
+	}
 }
 
 // ClearLogs removes all log entries
 func (m *Model) ClearLogs() {
 	m.logs = []LogEntry{}
 	m.logViewStart = 0
+	m.logCursor = -1
 }
 
 // IncrementRestartCount increases the restart counter
@@ -178,15 +224,88 @@ func (m *Model) SetBuildStatus(status BuildStatus) {
 	m.status = status
 }
 
+// watchDirs returns the watched roots as a single display string
+func (m Model) watchDirs() string {
+	return strings.Join(m.watchConfig.Roots, ", ")
+}
+
 // GetLogChan returns the channel for sending log messages
 func (m *Model) GetLogChan() chan LogMessage {
 	return m.logChan
 }
 
+const (
+	// logFrameRows are the rows around the log lines: the status and help
+	// bars, the log panel's border and padding, and its scroll indicator
+	logFrameRows = 7
+
+	// minLogRows is the log panel height the panes below it leave free
+	minLogRows = 3
+)
+
 // getMaxVisibleLogs calculates how many log lines can fit in the current view
 func (m *Model) getMaxVisibleLogs() int {
-	// Height minus status bar, help bar, and log panel borders/padding
-	return m.height - 6
+	// Height minus status bar, help bar, lower panes, and log panel borders/padding
+	rows := m.height - logFrameRows - m.panesHeight()
+	if rows < 0 {
+		rows = 0
+	}
+	return rows
+}
+
+// panesHeight is the height taken by the pipeline bar and the panes below
+// the log panel
+func (m Model) panesHeight() int {
+	return m.pipelineBarHeight() + m.problemsPanelHeight() + m.testsPanelHeight()
+}
+
+// paneRows splits the rows left over after the log panel between the
+// problems and failing tests panes. Each visible pane keeps at least one
+// row; when both want more than fits, they get half each, with any room
+// one of them doesn't need going to the other.
+func (m Model) paneRows() (problems, tests int) {
+	if len(m.problems) > 0 {
+		problems = m.wantedProblemRows()
+	}
+	tests = m.wantedTestLines()
+
+	visible := 0
+	if problems > 0 {
+		visible++
+	}
+	if tests > 0 {
+		visible++
+	}
+	// Every pane also takes a title line and its border
+	avail := m.height - logFrameRows - m.pipelineBarHeight() - minLogRows - 3*visible
+	if avail < visible {
+		avail = visible
+	}
+	if problems+tests <= avail {
+		return problems, tests
+	}
+
+	half := avail / 2
+	switch {
+	case problems <= half:
+		tests = avail - problems
+	case tests <= avail-half:
+		problems = avail - tests
+	default:
+		problems, tests = half, avail-half
+	}
+	return problems, tests
+}
+
+// clampLogView keeps the log view within range after the panel was resized
+func (m *Model) clampLogView() {
+	maxStart := len(m.logs) - m.getMaxVisibleLogs()
+	if maxStart < 0 {
+		maxStart = 0
+	}
+	if m.logViewStart > maxStart {
+		m.logViewStart = maxStart
+	}
 }
 
 // getVisibleLogs returns the logs that should be displayed in the current view
@@ -199,6 +318,9 @@ func (m *Model) getVisibleLogs() []LogEntry {
 	}
 
 	start := m.logViewStart
+	if start > totalLogs {
+		start = totalLogs
+	}
 	end := start + maxVisible
 
 	if end > totalLogs {
@@ -250,6 +372,9 @@ func (m Model) formatLogEntry(entry LogEntry) string {
 	case LogTypeEvent:
 		logType = m.styles.LogEntryEvent
 		typeString = "EVENT"
+	case LogTypeExit:
+		logType = m.styles.LogEntryExit
+		typeString = "EXIT"
 	default:
 		logType = m.styles.LogEntryInfo
 		typeString = "LOG"
@@ -268,10 +393,18 @@ func (m Model) buildStatusString() string {
 		return m.styles.StatusWatching.Render("Watching")
 	case StatusBuilding:
 		return m.styles.StatusBuilding.Render("Building...")
+	case StatusStarting:
+		return m.styles.StatusBuilding.Render("Starting...")
 	case StatusSuccess:
 		return m.styles.StatusSuccess.Render("Running")
 	case StatusError:
 		return m.styles.StatusError.Render("Build Failed")
+	case StatusErrorServing:
+		return m.styles.StatusError.Render("Build failed (serving previous build)")
+	case StatusNotReady:
+		return m.styles.StatusError.Render("Not Ready")
+	case StatusExited:
+		return m.styles.StatusError.Render("Exited")
 	default:
 		return "Unknown"
 	}
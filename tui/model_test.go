@@ -0,0 +1,102 @@
+package tui
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/jerkeyray/goober/internal"
+)
+
+// failingModel returns a model with build problems, a failing test and a
+// full log, sized to width x height
+func failingModel(width, height int) Model {
+	m := NewModel(internal.DefaultWatchConfig())
+	updated, _ := m.Update(tea.WindowSizeMsg{Width: width, Height: height})
+	m = updated.(Model)
+
+	var diags []internal.Diagnostic
+	for i := 1; i <= 10; i++ {
+		diags = append(diags, internal.Diagnostic{File: "main.go", Line: i, Column: 2, Message: "undefined: x"})
+	}
+	m.SetDiagnostics(diags)
+
+	output := strings.Repeat("    main_test.go:12: want 1, got 2\n", 8) + "--- FAIL: TestBad (0.00s)"
+	m.SetTestReport(internal.TestReport{
+		Failed:   1,
+		Failures: []internal.TestFailure{{Package: "example.com/a", Test: "TestBad", Output: output}},
+	})
+
+	for i := 0; i < 50; i++ {
+		m.AddLog(fmt.Sprintf("log line %d", i), LogTypeInfo)
+	}
+	return m
+}
+
+func TestViewWithProblemsAndFailingTests(t *testing.T) {
+	for _, size := range []struct{ width, height int }{
+		{80, 24},
+		{80, 11},
+		{80, 5},
+	} {
+		t.Run(fmt.Sprintf("%dx%d", size.width, size.height), func(t *testing.T) {
+			m := failingModel(size.width, size.height)
+			view := m.View()
+
+			if got := m.getMaxVisibleLogs(); got < 0 {
+				t.Errorf("getMaxVisibleLogs() = %d, want >= 0", got)
+			}
+			if !strings.Contains(view, "Problems (10)") || !strings.Contains(view, "Failing tests (1)") {
+				t.Errorf("View() is missing a pane:\n%s", view)
+			}
+			if size.height >= 24 {
+				if lines := strings.Count(view, "\n") + 1; lines > size.height {
+					t.Errorf("View() is %d lines, want at most %d:\n%s", lines, size.height, view)
+				}
+				if got := m.getMaxVisibleLogs(); got < minLogRows {
+					t.Errorf("getMaxVisibleLogs() = %d, want at least %d", got, minLogRows)
+				}
+			}
+		})
+	}
+}
+
+func TestLogCursor(t *testing.T) {
+	m := NewModel(internal.DefaultWatchConfig())
+	updated, _ := m.Update(tea.WindowSizeMsg{Width: 80, Height: 24})
+	m = updated.(Model)
+
+	m.AddLog("Building...", LogTypeInfo)
+	m.AddLog("./main.go:3:2: undefined: x", LogTypeError)
+	m.AddLog("./main.go:7:9: undefined: y", LogTypeError)
+	m.AddLog("Build failed", LogTypeError)
+
+	key := func(k string) {
+		updated, _ := m.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune(k)})
+		m = updated.(Model)
+	}
+	wantLine := func(line int) {
+		t.Helper()
+		loc, ok := m.selectedLocation()
+		if !ok || loc.Line != line {
+			t.Errorf("selectedLocation() = %v, %v, want line %d", loc, ok, line)
+		}
+	}
+
+	// The first error of the build is selected to begin with
+	wantLine(3)
+	key("j")
+	wantLine(7)
+	key("j")
+	wantLine(7)
+	key("k")
+	wantLine(3)
+	key("k")
+	wantLine(3)
+
+	m.ClearLogs()
+	if _, ok := m.selectedLocation(); ok {
+		t.Errorf("selectedLocation() after ClearLogs is set")
+	}
+}
@@ -0,0 +1,192 @@
+package tui
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/jerkeyray/goober/internal"
+)
+
+// Panel identifies which pane has keyboard focus
+type Panel int
+
+const (
+	PanelLogs Panel = iota
+	PanelProblems
+	PanelTests
+)
+
+// Problem is a diagnostic shown in the problems pane. The same error
+// reported by consecutive builds is kept as one entry.
+type Problem struct {
+	internal.Diagnostic
+	Builds    int // consecutive builds that reported it
+	FirstSeen time.Time
+}
+
+// DiagnosticsMsg carries the diagnostics of a finished build
+type DiagnosticsMsg struct {
+	Diagnostics []internal.Diagnostic
+}
+
+// SetDiagnostics replaces the problem list with the latest build's
+// diagnostics, carrying over problems that were already reported
+func (m *Model) SetDiagnostics(diags []internal.Diagnostic) {
+	previous := make(map[string]Problem, len(m.problems))
+	for _, p := range m.problems {
+		previous[p.Key()] = p
+	}
+
+	problems := make([]Problem, 0, len(diags))
+	for _, diag := range diags {
+		problem := Problem{Diagnostic: diag, Builds: 1, FirstSeen: time.Now()}
+		if old, ok := previous[diag.Key()]; ok {
+			problem.Builds = old.Builds + 1
+			problem.FirstSeen = old.FirstSeen
+		}
+		problems = append(problems, problem)
+	}
+	m.problems = problems
+
+	if m.problemCursor >= len(m.problems) {
+		m.problemCursor = len(m.problems) - 1
+	}
+	if m.problemCursor < 0 {
+		m.problemCursor = 0
+	}
+	m.fixFocus()
+	m.clampLogView()
+}
+
+// panels returns the panes that can take focus, in tab order
+func (m Model) panels() []Panel {
+	panels := []Panel{PanelLogs}
+	if len(m.problems) > 0 {
+		panels = append(panels, PanelProblems)
+	}
+	if len(m.failingTests()) > 0 {
+		panels = append(panels, PanelTests)
+	}
+	return panels
+}
+
+// toggleFocus moves keyboard focus to the next visible pane
+func (m *Model) toggleFocus() {
+	panels := m.panels()
+	for i, panel := range panels {
+		if panel == m.focus {
+			m.focus = panels[(i+1)%len(panels)]
+			return
+		}
+	}
+	m.focus = PanelLogs
+}
+
+// fixFocus moves focus back to the logs if the focused pane went away
+func (m *Model) fixFocus() {
+	for _, panel := range m.panels() {
+		if panel == m.focus {
+			return
+		}
+	}
+	m.focus = PanelLogs
+}
+
+// problemUp moves the problem selection up
+func (m *Model) problemUp() {
+	if m.problemCursor > 0 {
+		m.problemCursor--
+	}
+}
+
+// problemDown moves the problem selection down
+func (m *Model) problemDown() {
+	if m.problemCursor < len(m.problems)-1 {
+		m.problemCursor++
+	}
+}
+
+// maxVisibleProblems is how many problem rows fit in the problems pane
+func (m Model) maxVisibleProblems() int {
+	rows, _ := m.paneRows()
+	return rows
+}
+
+// wantedProblemRows is how many rows the problems pane would like, before
+// it shares the screen with the other panes
+func (m Model) wantedProblemRows() int {
+	rows := (m.height - 2) / 3
+	if rows < 3 {
+		rows = 3
+	}
+	if rows > len(m.problems) {
+		rows = len(m.problems)
+	}
+	return rows
+}
+
+// problemsPanelHeight is the height of the problems pane, or 0 when hidden
+func (m Model) problemsPanelHeight() int {
+	if len(m.problems) == 0 {
+		return 0
+	}
+	// Rows plus the title line and the border
+	return m.maxVisibleProblems() + 3
+}
+
+// renderProblemsPanel creates the navigable list of build problems
+func (m Model) renderProblemsPanel() string {
+	rows := m.maxVisibleProblems()
+	start := m.problemCursor - rows + 1
+	if start < 0 {
+		start = 0
+	}
+
+	title := m.styles.ProblemsTitle.Render(fmt.Sprintf("Problems (%d)", len(m.problems)))
+	if m.focus == PanelProblems {
+		title += m.styles.HelpDesc.Render("  ↑/↓ select • o open • tab next pane")
+	}
+	lines := []string{title}
+	for i := start; i < start+rows && i < len(m.problems); i++ {
+		lines = append(lines, m.formatProblem(m.problems[i], m.focus == PanelProblems && i == m.problemCursor))
+	}
+
+	style := m.styles.ProblemsPanel
+	if m.focus == PanelProblems {
+		style = style.BorderForeground(m.styles.ProblemsTitle.GetForeground())
+	}
+	return style.Width(m.width - 2).Render(strings.Join(lines, "\n"))
+}
+
+// formatProblem styles one problem row
+func (m Model) formatProblem(p Problem, selected bool) string {
+	location := fmt.Sprintf("%s:%d", p.File, p.Line)
+	if p.Column > 0 {
+		location += fmt.Sprintf(":%d", p.Column)
+	}
+	message := strings.SplitN(p.Message, "\n", 2)[0]
+
+	row := fmt.Sprintf("%s %s %s",
+		m.styles.LogEntryError.Render("✗"),
+		m.styles.LogEntryEvent.Render(location),
+		m.styles.LogEntryInfo.Render(message))
+	if p.Package != "" {
+		row += " " + m.styles.LogTimestamp.Render(p.Package)
+	}
+	if p.Builds > 1 {
+		row += " " + m.styles.LogEntryRestart.Render(fmt.Sprintf("×%d", p.Builds))
+	}
+	if selected {
+		return m.styles.ProblemSelected.Render("▶ " + row)
+	}
+	return "  " + row
+}
+
+// problemsStatusString returns the styled problem count for the status bar
+func (m Model) problemsStatusString() string {
+	if len(m.problems) == 0 {
+		return m.styles.StatusSuccess.Render("0")
+	}
+	return m.styles.StatusError.Render(fmt.Sprintf("%d", len(m.problems)))
+}
@@ -0,0 +1,78 @@
+package tui
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/jerkeyray/goober/internal"
+)
+
+// StageMsg carries a pipeline stage changing state
+type StageMsg struct {
+	Event internal.StageEvent
+}
+
+// SetStage records the state of a pipeline stage
+func (m *Model) SetStage(event internal.StageEvent) {
+	hadBar := m.pipelineBarHeight()
+	for len(m.stages) <= event.Index {
+		m.stages = append(m.stages, internal.StageEvent{Index: len(m.stages)})
+	}
+	m.stages[event.Index] = event
+	if m.pipelineBarHeight() != hadBar {
+		m.clampLogView()
+	}
+}
+
+// pipelineBarHeight is the height of the pipeline bar. A lone build stage
+// is already covered by the build status, so the bar only shows up for
+// pipelines with several stages.
+func (m Model) pipelineBarHeight() int {
+	if len(m.stages) > 1 {
+		return 1
+	}
+	return 0
+}
+
+// renderPipelineBar shows every stage with its state and timing
+func (m Model) renderPipelineBar() string {
+	items := make([]string, len(m.stages))
+	for i, stage := range m.stages {
+		var icon string
+		style := m.styles.LogTimestamp
+		switch stage.State {
+		case internal.StageRunning:
+			icon, style = "●", m.styles.StatusBuilding
+		case internal.StageSucceeded:
+			icon, style = "✓", m.styles.StatusSuccess
+		case internal.StageFailed:
+			icon, style = "✗", m.styles.StatusError
+		case internal.StageSkipped:
+			icon = "↷"
+		case internal.StageCancelled:
+			icon = "–"
+		default:
+			icon = "○"
+		}
+
+		item := style.Render(icon) + " " + m.styles.StatusBarValue.Render(stage.Name)
+		if stage.Elapsed > 0 {
+			item += " " + m.styles.LogTimestamp.Render(formatStageTime(stage.Elapsed))
+		}
+		items[i] = item
+	}
+
+	content := fmt.Sprintf("%s %s",
+		m.styles.StatusBarKey.Render("Pipeline:"),
+		strings.Join(items, m.styles.LogEntryInfo.Render(" → ")))
+	return m.styles.StatusBar.Width(m.width).MaxHeight(1).Render(content)
+}
+
+// formatStageTime rounds a stage's duration for display
+func formatStageTime(d time.Duration) string {
+	if d >= time.Second {
+		return d.Round(10 * time.Millisecond).String()
+	}
+	return d.Round(time.Millisecond).String()
+}
@@ -0,0 +1,560 @@
+package tui
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+	"github.com/jerkeyray/goober/internal"
+)
+
+// TestTUI is the terminal UI of test-only mode
+type TestTUI struct {
+	program *tea.Program
+	model   TestModel
+	runner  *internal.Runner
+}
+
+// TestModel shows a live tree of packages and tests
+type TestModel struct {
+	watchConfig internal.WatchConfig
+	tree        testTree
+	cursor      int
+	viewStart   int
+	lastLog     LogMessage
+
+	// Editing the -run filter
+	filter     string
+	editing    bool
+	filterEdit string
+
+	width  int
+	height int
+	styles Styles
+}
+
+// testEventMsg carries a single go test -json event
+type testEventMsg struct {
+	event internal.TestEvent
+}
+
+// testLogMsg carries a runner log line for the bottom bar
+type testLogMsg struct {
+	message string
+	isError bool
+}
+
+// testTickMsg refreshes running times while tests run
+type testTickMsg struct{}
+
+// runTestsMsg asks the TestTUI to start a test run
+type runTestsMsg struct {
+	pkgs    []string
+	pattern string
+	filter  bool // pattern becomes the new filter for later runs
+}
+
+// NewTestTUI creates the test-only TUI and hooks it up to runner
+func NewTestTUI(watchConfig internal.WatchConfig, runner *internal.Runner) *TestTUI {
+	t := &TestTUI{
+		model: TestModel{
+			watchConfig: watchConfig,
+			filter:      runner.TestFilter(),
+			width:       80,
+			height:      24,
+			styles:      makeStyles(),
+		},
+		runner: runner,
+	}
+
+	runner.SetLogCallback(t.handleLog)
+	runner.SetTestCallback(t.handleReport)
+	runner.SetTestEventCallback(t.handleEvent)
+
+	t.program = tea.NewProgram(t, tea.WithAltScreen())
+	return t
+}
+
+// Start runs the TUI until the user quits
+func (t *TestTUI) Start() error {
+	_, err := t.program.Run()
+	return err
+}
+
+// Stop stops the TUI and any test run
+func (t *TestTUI) Stop() {
+	t.runner.Stop()
+	t.program.Quit()
+}
+
+func (t *TestTUI) handleLog(message string, isError bool) {
+	t.program.Send(testLogMsg{message: message, isError: isError})
+}
+
+func (t *TestTUI) handleReport(report internal.TestReport) {
+	t.program.Send(TestReportMsg{Report: report})
+}
+
+func (t *TestTUI) handleEvent(event internal.TestEvent) {
+	t.program.Send(testEventMsg{event: event})
+}
+
+// Init implements tea.Model
+func (t TestTUI) Init() tea.Cmd {
+	return nil
+}
+
+// Update implements tea.Model
+func (t TestTUI) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
+	// Test runs are started here, where the runner is known
+	if run, ok := msg.(runTestsMsg); ok {
+		if run.filter {
+			t.runner.SetTestFilter(run.pattern)
+		}
+		go t.runner.RunTests(run.pkgs, run.pattern)
+		return t, nil
+	}
+
+	updated, cmd := t.model.Update(msg)
+	t.model = updated.(TestModel)
+	return t, cmd
+}
+
+// View implements tea.Model
+func (t TestTUI) View() string {
+	return t.model.View()
+}
+
+// Init implements tea.Model
+func (m TestModel) Init() tea.Cmd {
+	return nil
+}
+
+// Update implements tea.Model
+func (m TestModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
+	switch msg := msg.(type) {
+	case tea.WindowSizeMsg:
+		m.width = msg.Width
+		m.height = msg.Height
+		m.scrollToCursor()
+		return m, nil
+
+	case tea.KeyMsg:
+		if m.editing {
+			return m.updateFilter(msg)
+		}
+		switch msg.String() {
+		case "ctrl+c", "q":
+			return m, tea.Quit
+
+		case "r":
+			return m, runTests(nil, m.filter, false)
+
+		case "f":
+			return m, m.rerunFailed()
+
+		case "/":
+			m.editing = true
+			m.filterEdit = m.filter
+			return m, nil
+
+		case "enter", " ":
+			rows := m.tree.rows()
+			if m.cursor < len(rows) {
+				if p := m.tree.packageOf(rows[m.cursor]); p != nil {
+					p.expanded = !p.showTests()
+					p.toggled = true
+					m.cursor = m.rowIndex(&p.testNode)
+				}
+			}
+			m.scrollToCursor()
+			return m, nil
+
+		case "up", "k":
+			if m.cursor > 0 {
+				m.cursor--
+			}
+			m.scrollToCursor()
+			return m, nil
+
+		case "down", "j":
+			if m.cursor < len(m.tree.rows())-1 {
+				m.cursor++
+			}
+			m.scrollToCursor()
+			return m, nil
+
+		case "pgup":
+			m.cursor = max(m.cursor-10, 0)
+			m.scrollToCursor()
+			return m, nil
+
+		case "pgdown":
+			m.cursor = max(min(m.cursor+10, len(m.tree.rows())-1), 0)
+			m.scrollToCursor()
+			return m, nil
+		}
+
+	case TestReportMsg:
+		if msg.Report.Running {
+			m.tree.begin(msg.Report.Run)
+			return m, testTick()
+		}
+		m.tree.end()
+		return m, nil
+
+	case testEventMsg:
+		selected := m.selectedRow()
+		m.tree.apply(msg.event)
+		m.keepSelection(selected)
+		return m, nil
+
+	case testLogMsg:
+		m.lastLog = LogMessage{Message: msg.message, Type: LogTypeInfo}
+		if msg.isError {
+			m.lastLog.Type = LogTypeError
+		}
+		return m, nil
+
+	case testTickMsg:
+		if m.tree.running {
+			return m, testTick()
+		}
+		return m, nil
+	}
+
+	return m, nil
+}
+
+// updateFilter handles keys while the -run filter is being edited
+func (m TestModel) updateFilter(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	switch msg.Type {
+	case tea.KeyEsc:
+		m.editing = false
+		return m, nil
+
+	case tea.KeyEnter:
+		if _, err := regexp.Compile(m.filterEdit); err != nil {
+			m.lastLog = LogMessage{Message: fmt.Sprintf("Invalid filter: %v", err), Type: LogTypeError}
+			return m, nil
+		}
+		m.editing = false
+		m.filter = m.filterEdit
+		return m, runTests(nil, m.filter, true)
+
+	case tea.KeyBackspace:
+		if m.filterEdit != "" {
+			runes := []rune(m.filterEdit)
+			m.filterEdit = string(runes[:len(runes)-1])
+		}
+		return m, nil
+
+	case tea.KeyCtrlU:
+		m.filterEdit = ""
+		return m, nil
+
+	case tea.KeyRunes, tea.KeySpace:
+		m.filterEdit += string(msg.Runes)
+		return m, nil
+
+	case tea.KeyCtrlC:
+		return m, tea.Quit
+	}
+	return m, nil
+}
+
+// rerunFailed reruns only the tests that failed last time
+func (m TestModel) rerunFailed() tea.Cmd {
+	pkgs, tests, whole := m.tree.failed()
+	if len(pkgs) == 0 {
+		return nil
+	}
+	pattern := ""
+	if !whole {
+		quoted := make([]string, len(tests))
+		for i, name := range tests {
+			quoted[i] = regexp.QuoteMeta(name)
+		}
+		pattern = "^(" + strings.Join(quoted, "|") + ")$"
+	}
+	return runTests(pkgs, pattern, false)
+}
+
+func runTests(pkgs []string, pattern string, filter bool) tea.Cmd {
+	return func() tea.Msg {
+		return runTestsMsg{pkgs: pkgs, pattern: pattern, filter: filter}
+	}
+}
+
+func testTick() tea.Cmd {
+	return tea.Tick(100*time.Millisecond, func(time.Time) tea.Msg {
+		return testTickMsg{}
+	})
+}
+
+// selectedRow returns the node under the cursor, if any
+func (m TestModel) selectedRow() *testNode {
+	rows := m.tree.rows()
+	if m.cursor < len(rows) {
+		return rows[m.cursor]
+	}
+	return nil
+}
+
+// rowIndex returns the row of node, or 0 if it isn't shown
+func (m TestModel) rowIndex(node *testNode) int {
+	for i, row := range m.tree.rows() {
+		if row == node {
+			return i
+		}
+	}
+	return 0
+}
+
+// keepSelection keeps the cursor on node while rows come and go around it
+func (m *TestModel) keepSelection(node *testNode) {
+	if node != nil {
+		m.cursor = m.rowIndex(node)
+	}
+	m.scrollToCursor()
+}
+
+// treeHeight is how many rows of the tree fit on screen
+func (m TestModel) treeHeight() int {
+	// Status bar, help bar, log line and the tree border
+	height := m.height - 5 - m.outputHeight()
+	if height < 1 {
+		height = 1
+	}
+	return height
+}
+
+// scrollToCursor scrolls the tree so that the cursor is visible
+func (m *TestModel) scrollToCursor() {
+	rows := len(m.tree.rows())
+	if m.cursor >= rows {
+		m.cursor = max(rows-1, 0)
+	}
+	height := m.treeHeight()
+	if m.cursor < m.viewStart {
+		m.viewStart = m.cursor
+	}
+	if m.cursor >= m.viewStart+height {
+		m.viewStart = m.cursor - height + 1
+	}
+	if m.viewStart > max(rows-height, 0) {
+		m.viewStart = max(rows-height, 0)
+	}
+}
+
+// selectedOutput returns the output shown for the selected row: that of
+// failed tests and packages only
+func (m TestModel) selectedOutput() []string {
+	node := m.selectedRow()
+	if node == nil || node.State != testFailed || strings.TrimSpace(node.Output) == "" {
+		return nil
+	}
+	return strings.Split(strings.TrimRight(node.Output, "\n"), "\n")
+}
+
+// outputHeight is the height of the output pane, or 0 when hidden
+func (m TestModel) outputHeight() int {
+	lines := len(m.selectedOutput())
+	if lines == 0 {
+		return 0
+	}
+	rows := min(lines, max((m.height-2)/3, 3))
+	// Rows plus the title line and the border
+	return rows + 3
+}
+
+// View implements tea.Model
+func (m TestModel) View() string {
+	sections := []string{m.renderStatusBar(), m.renderTree()}
+	if m.outputHeight() > 0 {
+		sections = append(sections, m.renderOutput())
+	}
+	sections = append(sections, m.renderLogLine(), m.renderHelpBar())
+	return lipgloss.JoinVertical(lipgloss.Top, sections...)
+}
+
+// renderStatusBar shows the watched roots, test counts, filter and status
+func (m TestModel) renderStatusBar() string {
+	passed, failed, skipped := m.tree.counts()
+	counts := m.styles.StatusSuccess.Render(fmt.Sprintf("✓%d", passed))
+	if failed > 0 {
+		counts += " " + m.styles.StatusError.Render(fmt.Sprintf("✗%d", failed))
+	}
+	if skipped > 0 {
+		counts += " " + m.styles.StatusWatching.Render(fmt.Sprintf("↷%d", skipped))
+	}
+
+	items := []string{
+		fmt.Sprintf("%s %s",
+			m.styles.StatusBarKey.Render("Watching:"),
+			m.styles.StatusBarValue.Render(strings.Join(m.watchConfig.Roots, ", "))),
+		fmt.Sprintf("%s %s",
+			m.styles.StatusBarKey.Render("Tests:"),
+			counts),
+	}
+	if m.filter != "" {
+		items = append(items, fmt.Sprintf("%s %s",
+			m.styles.StatusBarKey.Render("Filter:"),
+			m.styles.StatusBarValue.Render(m.filter)))
+	}
+	items = append(items, fmt.Sprintf("%s %s",
+		m.styles.StatusBarKey.Render("Status:"),
+		m.statusString(failed)))
+
+	separator := m.styles.LogEntryInfo.Render(" │ ")
+	return m.styles.StatusBar.Width(m.width).Render(strings.Join(items, separator))
+}
+
+// statusString summarizes the last run
+func (m TestModel) statusString(failed int) string {
+	switch {
+	case m.tree.running:
+		return m.styles.StatusBuilding.Render("Testing...")
+	case len(m.tree.packages) == 0:
+		return m.styles.StatusWatching.Render("Watching")
+	case failed > 0 || m.anyPackageFailed():
+		return m.styles.StatusError.Render("Failed")
+	default:
+		return m.styles.StatusSuccess.Render("Passed")
+	}
+}
+
+func (m TestModel) anyPackageFailed() bool {
+	for _, p := range m.tree.packages {
+		if p.State == testFailed {
+			return true
+		}
+	}
+	return false
+}
+
+// renderTree draws the package and test tree
+func (m TestModel) renderTree() string {
+	rows := m.tree.rows()
+	height := m.treeHeight()
+	now := time.Now()
+
+	var lines []string
+	if len(rows) == 0 {
+		lines = append(lines, m.styles.LogEntryInfo.Render("✨ Waiting for test results..."))
+	}
+	for i := m.viewStart; i < len(rows) && i < m.viewStart+height; i++ {
+		line := m.formatRow(rows[i], now)
+		if i == m.cursor {
+			line = m.styles.ProblemSelected.Render("▶ " + line)
+		} else {
+			line = "  " + line
+		}
+		lines = append(lines, line)
+	}
+
+	return m.styles.ProblemsPanel.
+		BorderForeground(m.styles.LogPanel.GetBorderTopForeground()).
+		Width(m.width - 2).
+		Height(height).
+		Render(strings.Join(lines, "\n"))
+}
+
+// formatRow styles one package or test row
+func (m TestModel) formatRow(node *testNode, now time.Time) string {
+	var icon string
+	var style lipgloss.Style
+	switch node.State {
+	case testRunning:
+		icon, style = "●", m.styles.StatusBuilding
+	case testPassed:
+		icon, style = "✓", m.styles.StatusSuccess
+	case testFailed:
+		icon, style = "✗", m.styles.StatusError
+	case testSkipped:
+		icon, style = "↷", m.styles.StatusWatching
+	default:
+		icon, style = "○", m.styles.LogTimestamp
+	}
+
+	name := node.Name
+	if node.Depth > 1 {
+		name = name[strings.LastIndex(name, "/")+1:]
+	}
+	if node.Depth == 0 {
+		marker := "▸"
+		if p := m.tree.packageOf(node); p != nil && p.showTests() {
+			marker = "▾"
+		}
+		name = marker + " " + m.styles.StatusBarValue.Bold(true).Render(name)
+	} else {
+		name = m.styles.LogEntryInfo.Render(name)
+	}
+
+	elapsed := node.elapsed(now)
+	timing := ""
+	if elapsed >= time.Second {
+		timing = " " + m.styles.LogTimestamp.Render(elapsed.Round(10*time.Millisecond).String())
+	} else if elapsed > 0 || node.State == testRunning {
+		timing = " " + m.styles.LogTimestamp.Render(elapsed.Round(time.Millisecond).String())
+	}
+
+	return strings.Repeat("  ", node.Depth) + style.Render(icon) + " " + name + timing
+}
+
+// renderOutput shows the output of the selected failed test or package
+func (m TestModel) renderOutput() string {
+	lines := m.selectedOutput()
+	rows := m.outputHeight() - 3
+	if len(lines) > rows {
+		// The end of the output is where the failure is
+		lines = lines[len(lines)-rows:]
+	}
+	for i, line := range lines {
+		lines[i] = m.styles.LogEntryInfo.Render(line)
+	}
+
+	title := m.styles.ProblemsTitle.Render("Output: " + m.selectedRow().Name)
+	return m.styles.ProblemsPanel.
+		Width(m.width - 2).
+		Render(strings.Join(append([]string{title}, lines...), "\n"))
+}
+
+// renderLogLine shows the latest message from the runner
+func (m TestModel) renderLogLine() string {
+	style := m.styles.LogTimestamp
+	if m.lastLog.Type == LogTypeError {
+		style = m.styles.LogEntryError
+	}
+	return lipgloss.NewStyle().Padding(0, 1).Width(m.width).MaxHeight(1).Render(style.Render(m.lastLog.Message))
+}
+
+// renderHelpBar shows the keys, or the filter being edited
+func (m TestModel) renderHelpBar() string {
+	if m.editing {
+		content := fmt.Sprintf("%s %s%s  %s",
+			m.styles.HelpKey.Render("-run"),
+			m.styles.StatusBarValue.Render(m.filterEdit),
+			m.styles.HelpKey.Render("█"),
+			m.styles.HelpDesc.Render("enter apply • esc cancel • empty clears"))
+		return m.styles.HelpBar.Width(m.width).Render(content)
+	}
+
+	keys := [][2]string{
+		{"q", "quit"},
+		{"r", "rerun"},
+		{"f", "rerun failed"},
+		{"/", "filter"},
+		{"enter", "expand"},
+		{"↑/↓/j/k", "move"},
+	}
+	items := make([]string, len(keys))
+	for i, key := range keys {
+		items[i] = fmt.Sprintf("%s %s", m.styles.HelpKey.Render(key[0]), m.styles.HelpDesc.Render(key[1]))
+	}
+	separator := m.styles.LogEntryInfo.Render(" • ")
+	return m.styles.HelpBar.Width(m.width).Render(strings.Join(items, separator))
+}
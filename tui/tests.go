@@ -0,0 +1,153 @@
+package tui
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/jerkeyray/goober/internal"
+)
+
+// TestReportMsg carries the state of the test stage
+type TestReportMsg struct {
+	Report internal.TestReport
+}
+
+// SetTestReport records a test run. While a run is in progress the
+// failures of the previous one stay visible.
+func (m *Model) SetTestReport(report internal.TestReport) {
+	m.testsRunning = report.Running
+	if report.Running || report.Cancelled {
+		return
+	}
+	m.testReport = &report
+
+	if m.testCursor >= len(report.Failures) {
+		m.testCursor = len(report.Failures) - 1
+	}
+	if m.testCursor < 0 {
+		m.testCursor = 0
+	}
+	m.fixFocus()
+	m.clampLogView()
+}
+
+// failingTests returns the failures of the last finished test run
+func (m Model) failingTests() []internal.TestFailure {
+	if m.testReport == nil {
+		return nil
+	}
+	return m.testReport.Failures
+}
+
+// testUp moves the failing test selection up
+func (m *Model) testUp() {
+	if m.testCursor > 0 {
+		m.testCursor--
+	}
+}
+
+// testDown moves the failing test selection down
+func (m *Model) testDown() {
+	if m.testCursor < len(m.failingTests())-1 {
+		m.testCursor++
+	}
+}
+
+// testLines lays out the failing tests, with the output of the selected
+// one under its name. It also returns the line of the selected test.
+func (m Model) testLines() ([]string, int) {
+	var lines []string
+	selectedLine := 0
+	for i, failure := range m.failingTests() {
+		name := m.styles.LogEntryError.Render("✗ " + failure.Name())
+		if i != m.testCursor {
+			lines = append(lines, "  "+name)
+			continue
+		}
+		selectedLine = len(lines)
+		if m.focus == PanelTests {
+			lines = append(lines, m.styles.ProblemSelected.Render("▶ "+name))
+		} else {
+			lines = append(lines, "  "+name)
+		}
+		for _, line := range strings.Split(failure.Output, "\n") {
+			lines = append(lines, "    "+m.styles.LogEntryInfo.Render(line))
+		}
+	}
+	return lines, selectedLine
+}
+
+// maxVisibleTestLines is how many lines fit in the failing tests pane
+func (m Model) maxVisibleTestLines() int {
+	_, rows := m.paneRows()
+	return rows
+}
+
+// wantedTestLines is how many lines the failing tests pane would like,
+// before it shares the screen with the other panes
+func (m Model) wantedTestLines() int {
+	if len(m.failingTests()) == 0 {
+		return 0
+	}
+	lines, _ := m.testLines()
+	rows := (m.height - 2) / 3
+	if rows < 3 {
+		rows = 3
+	}
+	if rows > len(lines) {
+		rows = len(lines)
+	}
+	return rows
+}
+
+// testsPanelHeight is the height of the failing tests pane, or 0 when hidden
+func (m Model) testsPanelHeight() int {
+	if len(m.failingTests()) == 0 {
+		return 0
+	}
+	// Lines plus the title line and the border
+	return m.maxVisibleTestLines() + 3
+}
+
+// renderTestsPanel creates the list of failing tests and their output
+func (m Model) renderTestsPanel() string {
+	lines, selected := m.testLines()
+	rows := m.maxVisibleTestLines()
+
+	// Keep the selected test's name at the top so its output is visible
+	start := selected
+	if start > len(lines)-rows {
+		start = len(lines) - rows
+	}
+	if start < 0 {
+		start = 0
+	}
+
+	title := m.styles.ProblemsTitle.Render(fmt.Sprintf("Failing tests (%d)", len(m.failingTests())))
+	if m.focus == PanelTests {
+		title += m.styles.HelpDesc.Render("  ↑/↓ select • tab next pane")
+	}
+	content := append([]string{title}, lines[start:start+rows]...)
+
+	style := m.styles.ProblemsPanel
+	if m.focus == PanelTests {
+		style = style.BorderForeground(m.styles.ProblemsTitle.GetForeground())
+	}
+	return style.Width(m.width - 2).Render(strings.Join(content, "\n"))
+}
+
+// testsStatusString returns the styled test counts for the status bar
+func (m Model) testsStatusString() string {
+	if m.testsRunning {
+		return m.styles.StatusBuilding.Render("Running...")
+	}
+	report := m.testReport
+	counts := m.styles.StatusSuccess.Render(fmt.Sprintf("✓%d", report.Passed))
+	if report.Failed > 0 || len(report.Failures) > 0 {
+		counts += " " + m.styles.StatusError.Render(fmt.Sprintf("✗%d", max(report.Failed, len(report.Failures))))
+	}
+	if report.Skipped > 0 {
+		counts += " " + m.styles.StatusWatching.Render(fmt.Sprintf("↷%d", report.Skipped))
+	}
+	return counts
+}
@@ -0,0 +1,258 @@
+package tui
+
+import (
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/jerkeyray/goober/internal"
+)
+
+// testState is the outcome of a package or test in the test tree
+type testState int
+
+const (
+	testRunning testState = iota
+	testPassed
+	testFailed
+	testSkipped
+	testCancelled
+)
+
+// testNode is a package or a (sub)test in the test tree
+type testNode struct {
+	Name    string // import path for packages, test name for tests
+	Depth   int    // 0 for packages, 1 for tests, one more per subtest level
+	State   testState
+	Started time.Time
+	Elapsed time.Duration
+	Output  string
+}
+
+// elapsed returns how long the node ran, or has been running so far
+func (n *testNode) elapsed(now time.Time) time.Duration {
+	if n.State == testRunning && !n.Started.IsZero() {
+		return now.Sub(n.Started)
+	}
+	return n.Elapsed
+}
+
+// finish records the final state of a node from a pass, fail or skip event
+func (n *testNode) finish(event internal.TestEvent) {
+	switch event.Action {
+	case "pass":
+		n.State = testPassed
+	case "fail":
+		n.State = testFailed
+	case "skip":
+		n.State = testSkipped
+	}
+	n.Elapsed = time.Duration(event.Elapsed * float64(time.Second))
+}
+
+// testPackage is a package node and the tests run in it, with subtests
+// kept right after their parent
+type testPackage struct {
+	testNode
+	tests    []*testNode
+	expanded bool
+	toggled  bool // expanded was set by the user
+}
+
+// showTests reports whether the package's tests are listed under it.
+// Running and failed packages open by themselves until the user decides.
+func (p *testPackage) showTests() bool {
+	if p.toggled {
+		return p.expanded
+	}
+	return p.State == testRunning || p.State == testFailed
+}
+
+// test returns the node for name, creating it after its parent's subtree
+func (p *testPackage) test(name string) *testNode {
+	for _, t := range p.tests {
+		if t.Name == name {
+			return t
+		}
+	}
+
+	node := &testNode{Name: name, Depth: 1 + strings.Count(name, "/")}
+	at := len(p.tests)
+	if i := strings.LastIndex(name, "/"); i >= 0 {
+		parent := name[:i]
+		for j, t := range p.tests {
+			if t.Name == parent || strings.HasPrefix(t.Name, parent+"/") {
+				at = j + 1
+			}
+		}
+	}
+	p.tests = append(p.tests, nil)
+	copy(p.tests[at+1:], p.tests[at:])
+	p.tests[at] = node
+	return node
+}
+
+// testTree is the live state of go test -json runs in test-only mode.
+// Packages keep their last results until they are tested again.
+type testTree struct {
+	packages []*testPackage
+	builds   map[string]string // compiler output per build import path
+	pattern  string            // -run pattern of the current run
+	running  bool
+}
+
+// begin starts a new run
+func (t *testTree) begin(pattern string) {
+	t.pattern = pattern
+	t.running = true
+	t.builds = map[string]string{}
+}
+
+// end finishes the current run; anything still running was cancelled
+func (t *testTree) end() {
+	t.running = false
+	for _, p := range t.packages {
+		if p.State == testRunning {
+			p.State = testCancelled
+		}
+		for _, test := range p.tests {
+			if test.State == testRunning {
+				test.State = testCancelled
+			}
+		}
+	}
+}
+
+// pkg returns the node for the package at path, creating it in order
+func (t *testTree) pkg(path string) *testPackage {
+	i := sort.Search(len(t.packages), func(i int) bool { return t.packages[i].Name >= path })
+	if i < len(t.packages) && t.packages[i].Name == path {
+		return t.packages[i]
+	}
+	p := &testPackage{testNode: testNode{Name: path}}
+	t.packages = append(t.packages, nil)
+	copy(t.packages[i+1:], t.packages[i:])
+	t.packages[i] = p
+	return p
+}
+
+// apply updates the tree with a go test -json event
+func (t *testTree) apply(event internal.TestEvent) {
+	if event.Action == "build-output" {
+		if t.builds == nil {
+			t.builds = map[string]string{}
+		}
+		t.builds[event.ImportPath] += event.Output
+		return
+	}
+	if event.Package == "" {
+		return
+	}
+
+	p := t.pkg(event.Package)
+	if event.Test == "" {
+		switch event.Action {
+		case "start":
+			p.State = testRunning
+			p.Started = event.Time
+			p.Output = ""
+			if t.pattern == "" {
+				// A full run replaces the package's tests; a filtered
+				// one only updates the tests it matches
+				p.tests = nil
+			}
+		case "output":
+			p.Output += event.Output
+		case "pass", "fail", "skip":
+			p.finish(event)
+			if build := t.builds[event.FailedBuild]; build != "" {
+				p.Output = build + p.Output
+			}
+		}
+		return
+	}
+
+	node := p.test(event.Test)
+	switch event.Action {
+	case "run":
+		node.State = testRunning
+		node.Started = event.Time
+		node.Output = ""
+	case "output":
+		node.Output += event.Output
+	case "pass", "fail", "skip":
+		node.finish(event)
+	}
+}
+
+// counts returns the number of tests by state across the tree
+func (t *testTree) counts() (passed, failed, skipped int) {
+	for _, p := range t.packages {
+		for _, test := range p.tests {
+			switch test.State {
+			case testPassed:
+				passed++
+			case testFailed:
+				failed++
+			case testSkipped:
+				skipped++
+			}
+		}
+	}
+	return passed, failed, skipped
+}
+
+// failed returns the failed packages and the top-level tests that failed in
+// them. A package that failed without a failing test (a build error) comes
+// back with no tests, meaning all of it has to run again.
+func (t *testTree) failed() (pkgs []string, tests []string, whole bool) {
+	seen := map[string]bool{}
+	for _, p := range t.packages {
+		if p.State != testFailed {
+			continue
+		}
+		pkgs = append(pkgs, p.Name)
+		found := false
+		for _, test := range p.tests {
+			if test.State == testFailed {
+				name, _, _ := strings.Cut(test.Name, "/")
+				found = true
+				if !seen[name] {
+					seen[name] = true
+					tests = append(tests, name)
+				}
+			}
+		}
+		if !found {
+			whole = true
+		}
+	}
+	return pkgs, tests, whole
+}
+
+// rows flattens the tree into the lines shown on screen
+func (t *testTree) rows() []*testNode {
+	var rows []*testNode
+	for _, p := range t.packages {
+		rows = append(rows, &p.testNode)
+		if p.showTests() {
+			rows = append(rows, p.tests...)
+		}
+	}
+	return rows
+}
+
+// packageOf returns the package node a row belongs to
+func (t *testTree) packageOf(row *testNode) *testPackage {
+	for _, p := range t.packages {
+		if &p.testNode == row {
+			return p
+		}
+		for _, test := range p.tests {
+			if test == row {
+				return p
+			}
+		}
+	}
+	return nil
+}
@@ -1,7 +1,7 @@
 package tui
 
 import (
-	"time"
+	"errors"
 
 	tea "github.com/charmbracelet/bubbletea"
 	"github.com/jerkeyray/goober/internal"
@@ -39,8 +39,8 @@ func (t TUI) View() string {
 }
 
 // NewTUI creates a new TUI instance
-func NewTUI(watchDir string, debounceDuration time.Duration, runner *internal.Runner) *TUI {
-	model := NewModel(watchDir, debounceDuration)
+func NewTUI(watchConfig internal.WatchConfig, runner *internal.Runner) *TUI {
+	model := NewModel(watchConfig)
 	
 	tui := &TUI{
 		model:  model,
@@ -50,6 +50,10 @@ func NewTUI(watchDir string, debounceDuration time.Duration, runner *internal.Ru
 	// Set up callbacks
 	runner.SetLogCallback(tui.handleLog)
 	runner.SetRestartCallback(tui.handleRestart)
+	runner.SetExitCallback(tui.handleExit)
+	runner.SetDiagnosticsCallback(tui.handleDiagnostics)
+	runner.SetTestCallback(tui.handleTests)
+	runner.SetStageCallback(tui.handleStage)
 	
 	// Create the Bubble Tea program with a custom update function that handles force restart
 	tui.program = tea.NewProgram(tui, tea.WithAltScreen(), tea.WithMouseCellMotion())
@@ -77,7 +81,9 @@ func (t *TUI) handleLog(message string, isError bool) {
 	}
 
 	// Check for specific patterns to determine log type
-	if contains(message, []string{"successful", "success", "✓", "completed"}) {
+	if contains(message, []string{"superseded"}) {
+		logType = LogTypeRestart
+	} else if contains(message, []string{"successful", "success", "✓", "completed", "ready in"}) {
 		logType = LogTypeSuccess
 	} else if contains(message, []string{"error", "failed", "fail", "✗", "panic"}) {
 		logType = LogTypeError
@@ -91,13 +97,45 @@ func (t *TUI) handleLog(message string, isError bool) {
 	}
 
 	// Update build status based on message content
-	if contains(message, []string{"build successful", "build completed"}) {
+	if contains(message, []string{"building..."}) {
+		t.program.Send(BuildStatusMsg{Status: StatusBuilding})
+	} else if contains(message, []string{"starting app..."}) {
+		t.program.Send(BuildStatusMsg{Status: StatusStarting})
+	} else if contains(message, []string{"ready in"}) {
 		t.program.Send(BuildStatusMsg{Status: StatusSuccess})
-	} else if contains(message, []string{"build failed", "build error"}) {
+	} else if contains(message, []string{"not ready after"}) {
+		t.program.Send(BuildStatusMsg{Status: StatusNotReady})
+	} else if contains(message, []string{"serving previous build"}) {
+		t.program.Send(BuildStatusMsg{Status: StatusErrorServing})
+	} else if contains(message, []string{"build failed", "build error", "failed to start app"}) {
 		t.program.Send(BuildStatusMsg{Status: StatusError})
 	}
 }
 
+// handleExit processes the app exiting on its own
+func (t *TUI) handleExit(event internal.ExitEvent) {
+	select {
+	case t.model.logChan <- LogMessage{Message: event.String(), Type: LogTypeExit}:
+	default:
+	}
+	t.program.Send(BuildStatusMsg{Status: StatusExited})
+}
+
+// handleDiagnostics passes the problems of a finished build to the model
+func (t *TUI) handleDiagnostics(diags []internal.Diagnostic) {
+	t.program.Send(DiagnosticsMsg{Diagnostics: diags})
+}
+
+// handleTests passes test stage reports to the model
+func (t *TUI) handleTests(report internal.TestReport) {
+	t.program.Send(TestReportMsg{Report: report})
+}
+
+// handleStage passes pipeline stage updates to the model
+func (t *TUI) handleStage(event internal.StageEvent) {
+	t.program.Send(StageMsg{Event: event})
+}
+
 // handleRestart processes restart events
 func (t *TUI) handleRestart() {
 	t.program.Send(RestartCountMsg{})
@@ -133,10 +171,10 @@ func (t *TUI) GetProgram() *tea.Program {
 func (t *TUI) ForceRestart() {
 	go func() {
 		t.handleLog("Manual restart triggered", false)
-		t.runner.Stop()
-		t.handleRestart()
-		if err := t.runner.Start(); err != nil {
-			t.handleLog("Manual restart failed: "+err.Error(), true)
+		if err := t.runner.Restart(); err != nil {
+			if !errors.Is(err, internal.ErrBuildSuperseded) {
+				t.handleLog("Manual restart failed: "+err.Error(), true)
+			}
 		} else {
 			t.handleLog("Manual restart successful", false)
 		}
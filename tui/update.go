@@ -19,6 +19,7 @@ func (m Model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 		m.width = msg.Width
 		m.height = msg.Height
 		m.ready = true
+		m.clampLogView()
 		return m, nil
 
 	case tea.KeyMsg:
@@ -34,12 +35,39 @@ func (m Model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 			m.ClearLogs()
 			return m, nil
 
+		case "tab":
+			m.toggleFocus()
+			return m, nil
+
+		case "o", "enter":
+			return m, m.openEditor()
+
 		case "up", "k":
-			m.scrollUp()
+			switch {
+			case m.focus == PanelProblems:
+				m.problemUp()
+			case m.focus == PanelTests:
+				m.testUp()
+			case msg.String() == "k":
+				// In the logs, j/k step between file:line entries and the
+				// arrows scroll
+				m.locationUp()
+			default:
+				m.scrollUp()
+			}
 			return m, nil
 
 		case "down", "j":
-			m.scrollDown()
+			switch {
+			case m.focus == PanelProblems:
+				m.problemDown()
+			case m.focus == PanelTests:
+				m.testDown()
+			case msg.String() == "j":
+				m.locationDown()
+			default:
+				m.scrollDown()
+			}
 			return m, nil
 
 		case "pgup":
@@ -70,6 +98,24 @@ func (m Model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 	case RestartCountMsg:
 		m.IncrementRestartCount()
 		return m, nil
+
+	case DiagnosticsMsg:
+		m.SetDiagnostics(msg.Diagnostics)
+		return m, nil
+
+	case StageMsg:
+		m.SetStage(msg.Event)
+		return m, nil
+
+	case TestReportMsg:
+		m.SetTestReport(msg.Report)
+		return m, nil
+
+	case editorFinishedMsg:
+		if msg.err != nil {
+			m.AddLog("Editor: "+msg.err.Error(), LogTypeError)
+		}
+		return m, nil
 	}
 
 	return m, nil
@@ -17,40 +17,59 @@ func (m Model) View() string {
 	helpBar := m.renderHelpBar()
 	logPanel := m.renderLogPanel()
 
-	return lipgloss.JoinVertical(
-		lipgloss.Top,
-		statusBar,
-		logPanel,
-		helpBar,
-	)
+	sections := []string{statusBar}
+	if m.pipelineBarHeight() > 0 {
+		sections = append(sections, m.renderPipelineBar())
+	}
+	sections = append(sections, logPanel)
+	if len(m.problems) > 0 {
+		sections = append(sections, m.renderProblemsPanel())
+	}
+	if len(m.failingTests()) > 0 {
+		sections = append(sections, m.renderTestsPanel())
+	}
+	sections = append(sections, helpBar)
+
+	return lipgloss.JoinVertical(lipgloss.Top, sections...)
 }
 
 // renderStatusBar creates the top status bar
 func (m Model) renderStatusBar() string {
 	watchDirItem := fmt.Sprintf("%s %s",
 		m.styles.StatusBarKey.Render("Watching:"),
-		m.styles.StatusBarValue.Render(m.watchDir))
+		m.styles.StatusBarValue.Render(m.watchDirs()))
 
 	debounceItem := fmt.Sprintf("%s %s",
 		m.styles.StatusBarKey.Render("Debounce:"),
-		m.styles.StatusBarValue.Render(m.debounceDuration.String()))
+		m.styles.StatusBarValue.Render(m.watchConfig.Debounce.String()))
 
 	restartItem := fmt.Sprintf("%s %s",
 		m.styles.StatusBarKey.Render("Restarts:"),
 		m.styles.StatusBarValue.Render(fmt.Sprintf("%d", m.restartCount)))
 
+	problemsItem := fmt.Sprintf("%s %s",
+		m.styles.StatusBarKey.Render("Problems:"),
+		m.problemsStatusString())
+
 	buildStatusItem := fmt.Sprintf("%s %s",
 		m.styles.StatusBarKey.Render("Status:"),
 		m.buildStatusString())
 
-	// Join status items with separators
+	items := []string{watchDirItem, debounceItem, restartItem}
+	if m.testReport != nil || m.testsRunning {
+		items = append(items, fmt.Sprintf("%s %s",
+			m.styles.StatusBarKey.Render("Tests:"),
+			m.testsStatusString()))
+	}
+	items = append(items, problemsItem, buildStatusItem)
+
+	// Join status items with separators. The debounce setting is the first
+	// to go on a narrow terminal, then items from the end.
 	separator := m.styles.LogEntryInfo.Render(" │ ")
-	statusContent := strings.Join([]string{
-		watchDirItem,
-		debounceItem,
-		restartItem,
-		buildStatusItem,
-	}, separator)
+	if lipgloss.Width(strings.Join(items, separator)) > m.width-2 {
+		items = append(items[:1], items[2:]...)
+	}
+	statusContent := joinToFit(items, separator, m.width-2)
 
 	// Apply styling and fit to width
 	return m.styles.StatusBar.Width(m.width).Render(statusContent)
@@ -63,9 +82,17 @@ func (m Model) renderLogPanel() string {
 		logContent = m.styles.LogEntryInfo.Render("✨ Watching for file changes...")
 	} else {
 		visibleLogs := m.getVisibleLogs()
+		selected := -1
+		if m.focus == PanelLogs {
+			selected = m.logCursor
+		}
 		var logLines []string
-		for _, entry := range visibleLogs {
-			logLines = append(logLines, m.formatLogEntry(entry))
+		for i, entry := range visibleLogs {
+			line := m.formatLogEntry(entry)
+			if m.logViewStart+i == selected {
+				line = m.styles.ProblemSelected.Render(line)
+			}
+			logLines = append(logLines, line)
 		}
 		logContent = strings.Join(logLines, "\n")
 	}
@@ -74,7 +101,7 @@ func (m Model) renderLogPanel() string {
 	totalLogs := len(m.logs)
 	maxVisible := m.getMaxVisibleLogs()
 	var scrollInfo string
-	if totalLogs > maxVisible {
+	if totalLogs > maxVisible && maxVisible > 0 {
 		start := m.logViewStart + 1
 		end := m.logViewStart + len(m.getVisibleLogs())
 		if end > totalLogs {
@@ -84,11 +111,11 @@ func (m Model) renderLogPanel() string {
 	}
 
 	// Combine log content and scroll indicator
-	logPanelHeight := m.height - 2 // Status bar and help bar
+	logPanelHeight := m.height - 2 - m.panesHeight() // Status bar, help bar, pipeline bar and lower panes
 	return m.styles.LogPanel.
 		Width(m.width - 2). // Account for border
 		Height(logPanelHeight - 2).
-		Render(lipgloss.JoinVertical(lipgloss.Left, logContent, lipgloss.PlaceHorizontal(m.width-2, lipgloss.Right, m.styles.LogEntryInfo.Render(scrollInfo))))
+		Render(lipgloss.JoinVertical(lipgloss.Left, logContent, lipgloss.PlaceHorizontal(m.width-6, lipgloss.Right, m.styles.LogEntryInfo.Render(scrollInfo))))
 }
 
 // renderHelpBar creates the bottom help bar
@@ -104,16 +131,42 @@ func (m Model) renderHelpBar() string {
 			m.styles.HelpKey.Render("c"),
 			m.styles.HelpDesc.Render("clear logs")),
 		fmt.Sprintf("%s %s",
-			m.styles.HelpKey.Render("↑/↓/j/k"),
+			m.styles.HelpKey.Render("o"),
+			m.styles.HelpDesc.Render("open")),
+		fmt.Sprintf("%s %s",
+			m.styles.HelpKey.Render("tab"),
+			m.styles.HelpDesc.Render("panes")),
+		fmt.Sprintf("%s %s",
+			m.styles.HelpKey.Render("j/k"),
+			m.styles.HelpDesc.Render("select")),
+		fmt.Sprintf("%s %s",
+			m.styles.HelpKey.Render("↑/↓"),
 			m.styles.HelpDesc.Render("scroll")),
 		fmt.Sprintf("%s %s",
 			m.styles.HelpKey.Render("PgUp/PgDn"),
-			m.styles.HelpDesc.Render("fast scroll")),
+			m.styles.HelpDesc.Render("page")),
 	}
 
 	separator := m.styles.LogEntryInfo.Render(" • ")
-	helpContent := strings.Join(helpItems, separator)
+	helpContent := joinToFit(helpItems, separator, m.width-2)
 
 	// Apply styling and fit to width
 	return m.styles.HelpBar.Width(m.width).Render(helpContent)
 }
+
+// joinToFit joins items with separator, leaving off the items at the end
+// that would not fit on a single line of width cells
+func joinToFit(items []string, separator string, width int) string {
+	content := ""
+	for i, item := range items {
+		next := item
+		if i > 0 {
+			next = content + separator + item
+		}
+		if lipgloss.Width(next) > width {
+			break
+		}
+		content = next
+	}
+	return content
+}